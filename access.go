@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var optLogFormat = flag.String("log-format", "text", "format of the access log; 'text' or 'json'")
+
+type accessEntry struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Size     int       `json:"size"`
+	Duration float64   `json:"duration_ms"`
+	ID       string    `json:"request_id"`
+}
+
+// statusWriter records the status code and number of bytes written so
+// they can be reported once the request is complete.
+type statusWriter struct {
+	http.ResponseWriter
+
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Flush lets handlers streaming their response, such as /livereload, send
+// what they have written so far.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func validLogFormat(format string) bool {
+	return format == "text" || format == "json"
+}
+
+func accessLog(h http.Handler) http.Handler {
+	enc := json.NewEncoder(log.Writer())
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		h.ServeHTTP(sw, r)
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		requestsTotal.WithLabelValues(strconv.Itoa(sw.status)).Inc()
+
+		e := accessEntry{
+			Time:     start,
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			Status:   sw.status,
+			Size:     sw.size,
+			Duration: float64(time.Since(start)) / float64(time.Millisecond),
+			ID:       getRequestID(r),
+		}
+
+		if *optLogFormat == "json" {
+			enc.Encode(e)
+		} else {
+			log.Printf("%v %v %v %v %.3fms %v\n", e.Method, e.Path, e.Status, e.Size, e.Duration, e.ID)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogNotFound(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About"})
+	logged := captureLog(t)
+
+	get(testHandler(), "/missing")
+	if !strings.Contains(logged.String(), "GET /missing 404 ") {
+		t.Errorf("log %q has no line for the 404", logged)
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	setFlag(t, "log-format", "json")
+	testSite(t, map[string]string{"about.md": "About"})
+	logged := captureLog(t)
+
+	get(testHandler(), "/missing")
+
+	var e accessEntry
+	if err := json.Unmarshal(logged.Bytes(), &e); err != nil {
+		t.Fatalf("log %q is not a JSON entry: %v", logged, err)
+	}
+	if e.Method != "GET" || e.Path != "/missing" || e.Status != 404 || e.ID == "" {
+		t.Errorf("entry %+v", e)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pageJSON is the view of a page served to API clients.
+type pageJSON struct {
+	Path    string     `json:"path"`
+	Lang    string     `json:"lang,omitempty"`
+	Title   string     `json:"title,omitempty"`
+	Date    *time.Time `json:"date,omitempty"`
+	Summary string     `json:"summary,omitempty"`
+	Tags    []string   `json:"tags,omitempty"`
+	Draft   bool       `json:"draft,omitempty"`
+	ModTime time.Time  `json:"modified"`
+	Reading int        `json:"reading_minutes,omitempty"`
+	TOC     []tocJSON  `json:"toc,omitempty"`
+	HTML    string     `json:"html"`
+}
+
+type tocJSON struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// wantsJSON reports whether the client asked for the JSON view of a page,
+// with ?format=json or an Accept header naming application/json.
+func wantsJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "json"
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if t, _, err := mime.ParseMediaType(accept); err == nil && t == "application/json" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, p string, cf *ContentFile) {
+	page := pageJSON{
+		Path:    p,
+		Lang:    cf.Lang,
+		Title:   cf.Title,
+		Summary: cf.Summary,
+		Tags:    cf.Tags,
+		Draft:   cf.Draft,
+		ModTime: cf.ModTime,
+		Reading: cf.ReadingTime,
+		HTML:    string(cf.Content),
+	}
+
+	if !cf.Date.IsZero() {
+		page.Date = &cf.Date.Time
+	}
+
+	for _, h := range cf.TOC {
+		page.TOC = append(page.TOC, tocJSON{h.Level, h.ID, h.Title})
+	}
+
+	b, err := json.Marshal(page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if cc := cf.CacheControl; cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	} else if *optCacheControl != "" {
+		w.Header().Set("Cache-Control", *optCacheControl)
+	}
+
+	w.Header().Set("Vary", "Accept, Accept-Encoding, Accept-Language")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	setCORS(w, r)
+	w.Write(b)
+}
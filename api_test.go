@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPageJSON(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "---\ntitle: About us\ndate: 2023-06-01\ntags: [team, company]\n---\n# Who\n\nWe make things.\n"})
+
+	for _, headers := range [][]string{nil, {"Accept", "text/html, application/json;q=0.9"}} {
+		u := "/about?format=json"
+		if headers != nil {
+			u = "/about"
+		}
+		w := get(http.HandlerFunc(renderPage), u, headers...)
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("%v %v: Content-Type %q", u, headers, ct)
+		}
+
+		var page pageJSON
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Path != "/about" || page.Title != "About us" || page.Date == nil || page.Date.Year() != 2023 || len(page.Tags) != 2 {
+			t.Errorf("%v %v: JSON view %+v", u, headers, page)
+		}
+		if !strings.Contains(page.HTML, "<p>We make things.</p>") || strings.Contains(page.HTML, "<html>") {
+			t.Errorf("%v %v: JSON view HTML %q, want the page without its layout", u, headers, page.HTML)
+		}
+	}
+}
+
+func TestPageHTMLUnaffectedByJSONView(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About"})
+
+	for _, u := range []string{"/about", "/about?format=html"} {
+		w := get(http.HandlerFunc(renderPage), u, "Accept", "text/html")
+		if ct := w.Header().Get("Content-Type"); strings.Contains(ct, "json") || !strings.HasPrefix(w.Body.String(), "<html>") {
+			t.Errorf("%v: Content-Type %q, body %q", u, ct, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var optDateFormat = flag.String("date-format", "2 January 2006", "Go time layout of the dates shown in archive listings")
+var optTimezone = flag.String("timezone", "", "time zone page dates are shown and grouped in, e.g. Europe/London; empty to keep their own")
+
+var timezone *time.Location
+
+var optPageSize = flag.Int("page-size", 0, "number of pages listed on each page of an archive; 0 to list them all")
+var optIndexTmpl = flag.String("index-tmpl", "", "html/template file, relative to the root, used to render archive listings")
+var optRecursiveArchives = flag.Bool("recursive-archives", false, "list the dated pages of sub-directories in the archives of their parents")
+
+var archivePattern = regexp.MustCompile(`^(.*/)(\d{4})/(?:(\d{2})/)?$`)
+
+var indexTemplate *template.Template
+var indexTemplateLock sync.RWMutex
+
+// archiveData is given to the -index-tmpl template, and served as JSON.
+// Entries holds the entries on the Page, of PageSize; PrevURL and NextURL
+// link the neighbouring pages, if there are any.
+type archiveData struct {
+	Title    string         `json:"title"`
+	Total    int            `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"pageSize"`
+	PrevURL  string         `json:"prev,omitempty"`
+	NextURL  string         `json:"next,omitempty"`
+	Entries  []archiveEntry `json:"entries"`
+}
+
+// DateText is the Date in -date-format, or empty for an undated page.
+type archiveEntry struct {
+	Name     string    `json:"name"`
+	Title    string    `json:"title"`
+	URL      string    `json:"url"`
+	Date     time.Time `json:"date"`
+	DateText string    `json:"-"`
+	Summary  string    `json:"summary,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+}
+
+// setupTimezone sets the location page dates are shown in to the
+// -timezone, which checkOptions has validated.
+func setupTimezone() {
+	if *optTimezone != "" {
+		timezone, _ = time.LoadLocation(*optTimezone)
+	}
+}
+
+// pageDate returns the page's date in the -timezone.
+func pageDate(cf *ContentFile) time.Time {
+	if timezone == nil || cf.Date.IsZero() {
+		return cf.Date.Time
+	}
+	return cf.Date.In(timezone)
+}
+
+func updateIndexTemplate() error {
+	var t *template.Template
+	if *optIndexTmpl != "" {
+		var err error
+		if t, err = template.ParseFS(fsys, sitePath(filepath.Join(root, *optIndexTmpl))); err != nil {
+			return err
+		}
+	}
+
+	indexTemplateLock.Lock()
+	indexTemplate = t
+	indexTemplateLock.Unlock()
+	return nil
+}
+
+func getIndexTemplate() *template.Template {
+	indexTemplateLock.RLock()
+	defer indexTemplateLock.RUnlock()
+	return indexTemplate
+}
+
+// findArchive interprets a URL path such as /blog/2023/ or /blog/2023/06/
+// as the archive of the dated pages in /blog/ for that year or month. The
+// directory must exist and hold at least one dated page.
+func findArchive(tree *Dir, p string) (string, *Dir, int, time.Month, bool) {
+	m := archivePattern.FindStringSubmatch(p)
+	if m == nil {
+		return "", nil, 0, 0, false
+	}
+
+	d := tree.FindByPath(m[1])
+	if d == nil || tree.FindByPath(p) != nil {
+		return "", nil, 0, 0, false
+	}
+
+	dated := false
+	walkArchive(m[1], d, func(_ string, dir *Dir) {
+		for _, cf := range dir.Files {
+			dated = dated || !cf.Date.IsZero()
+		}
+	})
+
+	year, _ := strconv.Atoi(m[2])
+	month := 0
+	if m[3] != "" {
+		if month, _ = strconv.Atoi(m[3]); month < 1 || month > 12 {
+			return "", nil, 0, 0, false
+		}
+	}
+
+	return m[1], d, year, time.Month(month), dated
+}
+
+// walkArchive calls fn for d, at p, and, if its archives are recursive,
+// each of its sub-directories.
+func walkArchive(p string, d *Dir, fn func(p string, dir *Dir)) {
+	if !*optRecursiveArchives && !d.Recursive {
+		fn(p, d)
+		return
+	}
+
+	d.Walk(func(sub string, dir *Dir) {
+		fn(p+strings.TrimPrefix(sub, "/"), dir)
+	})
+}
+
+type archivePage struct {
+	url  string
+	name string
+	cf   *ContentFile
+}
+
+// writeArchive lists the pages in d dated in the year, and month if it is
+// not zero, newest first.
+func writeArchive(w http.ResponseWriter, r *http.Request, p string, d *Dir, year int, month time.Month) {
+	var pages []archivePage
+	seen := make(map[string]bool)
+	walkArchive(p, d, func(dp string, dir *Dir) {
+		for _, n := range dir.FileNames() {
+			cf := dir.Files[n]
+			date := pageDate(cf)
+			if date.IsZero() || date.Year() != year || (month != 0 && date.Month() != month) {
+				continue
+			}
+			if (dp == "/" && n == *optNotFound) || (cf.Draft && !*optShowDrafts) {
+				continue
+			}
+
+			// a recursive archive can reach beneath -auth-prefix
+			u := linkURL(dir, dp, n)
+			if protected(u) && !protected(p) {
+				continue
+			}
+			if !seen[u] {
+				seen[u] = true
+				pages = append(pages, archivePage{u, n, cf})
+			}
+		}
+	})
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].cf.Date.After(pages[j].cf.Date.Time)
+	})
+
+	title := strconv.Itoa(year)
+	if month != 0 {
+		title = month.String() + " " + title
+	}
+
+	entries := make([]archiveEntry, 0, len(pages))
+	for _, page := range pages {
+		cf := page.cf
+		e := archiveEntry{Name: page.name, Title: cf.Title, URL: page.url, Date: pageDate(cf), Summary: cf.Summary, Tags: cf.Tags}
+		if !e.Date.IsZero() {
+			e.DateText = e.Date.Format(*optDateFormat)
+		}
+		if e.Title == "" {
+			e.Title = page.name
+		}
+		entries = append(entries, e)
+	}
+
+	data := archiveData{Title: title, Total: len(entries), Page: 1, PageSize: len(entries), Entries: entries}
+	if *optPageSize > 0 {
+		data.PageSize = *optPageSize
+		if n, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && n > 1 {
+			data.Page = n
+		}
+
+		start, end := (data.Page-1)*data.PageSize, data.Page*data.PageSize
+		if start > len(entries) {
+			start = len(entries)
+		}
+		if end > len(entries) {
+			end = len(entries)
+		}
+		data.Entries = entries[start:end]
+
+		if data.Page > 1 {
+			data.PrevURL = r.URL.Path + "?page=" + strconv.Itoa(data.Page-1)
+		}
+		if end < len(entries) {
+			data.NextURL = r.URL.Path + "?page=" + strconv.Itoa(data.Page+1)
+		}
+	}
+
+	if wantsJSON(r) {
+		b, _ := json.Marshal(data)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Vary", "Accept, Accept-Encoding, Accept-Language")
+		setCORS(w, r)
+		w.Write(b)
+		return
+	}
+
+	b := &bytes.Buffer{}
+	if t := getIndexTemplate(); t != nil {
+		if err := t.Execute(b, data); err != nil {
+			log.Printf("Could not render the archive %v. %v\n", r.URL.Path, err)
+			writeError(w, r, getTree(), 500, "Could not render the archive!")
+			return
+		}
+	} else {
+		writeArchiveList(b, data)
+	}
+
+	cf := &ContentFile{Content: b.Bytes()}
+	cf.Title = title
+	cf.CanonicalHTML = canonicalHTML(absoluteURL(r.URL.Path))
+	cf.Hash = pageHash(cf)
+	for _, page := range pages {
+		if t := page.cf.ModTime; t.After(cf.ModTime) {
+			cf.ModTime = t
+		}
+	}
+	write(w, r, 200, cf, viewLayout(r, d, d.Layout))
+}
+
+func writeArchiveList(b *bytes.Buffer, data archiveData) {
+	b.WriteString("<h2>" + data.Title + "</h2>\n")
+	if data.Total == 0 {
+		b.WriteString("<p>No pages were published in " + data.Title + ".</p>\n")
+		return
+	}
+
+	b.WriteString("<ul class=\"archive\">\n")
+	for _, e := range data.Entries {
+		fmt.Fprintf(b, "<li><a href=\"%v\">%v</a>", html.EscapeString(e.URL), html.EscapeString(e.Title))
+		if e.DateText != "" {
+			fmt.Fprintf(b, " <time datetime=\"%v\">%v</time>", e.Date.Format(time.RFC3339), html.EscapeString(e.DateText))
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n")
+
+	if data.PrevURL != "" || data.NextURL != "" {
+		b.WriteString("<nav class=\"pages\">")
+		if data.PrevURL != "" {
+			fmt.Fprintf(b, "<a href=\"%v\" rel=\"prev\">Newer</a>", html.EscapeString(data.PrevURL))
+		}
+		if data.NextURL != "" {
+			fmt.Fprintf(b, " <a href=\"%v\" rel=\"next\">Older</a>", html.EscapeString(data.NextURL))
+		}
+		b.WriteString("</nav>\n")
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// archiveSite has blog posts from two months of 2023 and one of 2022.
+func archiveSite(t *testing.T) *Dir {
+	t.Helper()
+
+	return testSite(t, map[string]string{
+		"blog/first.md":   "---\ntitle: First\ndate: 2022-12-31\n---\nFirst",
+		"blog/second.md":  "---\ntitle: Second\ndate: 2023-01-15\n---\nSecond",
+		"blog/third.md":   "---\ntitle: Third\ndate: 2023-06-01\n---\nThird",
+		"blog/undated.md": "Undated",
+		"about.md":        "About",
+	})
+}
+
+func TestArchiveOfYear(t *testing.T) {
+	archiveSite(t)
+
+	w := get(http.HandlerFunc(renderPage), "/blog/2023/")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %v, want 200", w.Code)
+	}
+	body := w.Body.String()
+	third, second := strings.Index(body, "Third"), strings.Index(body, "Second")
+	if third < 0 || second < 0 || third > second {
+		t.Errorf("2023 archive %q does not list Third then Second", body)
+	}
+	if strings.Contains(body, "First") || strings.Contains(body, "Undated") {
+		t.Errorf("2023 archive %q lists pages from other periods", body)
+	}
+	if !strings.Contains(body, "<title>2023</title>") {
+		t.Errorf("2023 archive %q is not titled with the year", body)
+	}
+}
+
+func TestArchiveOfMonth(t *testing.T) {
+	archiveSite(t)
+
+	body := get(http.HandlerFunc(renderPage), "/blog/2023/06/").Body.String()
+	if !strings.Contains(body, "Third") || strings.Contains(body, "Second") {
+		t.Errorf("June 2023 archive %q", body)
+	}
+	if !strings.Contains(body, "<title>June 2023</title>") {
+		t.Errorf("June 2023 archive %q is not titled with the month", body)
+	}
+}
+
+func TestArchiveOfEmptyPeriod(t *testing.T) {
+	archiveSite(t)
+
+	w := get(http.HandlerFunc(renderPage), "/blog/2020/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "No pages were published in 2020.") {
+		t.Errorf("2020 archive: status %v, body %q", w.Code, w.Body.String())
+	}
+}
+
+func TestArchiveNotFound(t *testing.T) {
+	archiveSite(t)
+
+	for _, u := range []string{"/blog/2023/13/", "/2023/", "/missing/2023/", "/blog/2023"} {
+		if w := get(http.HandlerFunc(renderPage), u); w.Code != http.StatusNotFound {
+			t.Errorf("%v: status %v, want 404", u, w.Code)
+		}
+	}
+}
+
+func TestArchiveConditionalRequests(t *testing.T) {
+	archiveSite(t)
+
+	w := get(http.HandlerFunc(renderPage), "/blog/2023/")
+	etag, modified := w.Header().Get("Etag"), w.Header().Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("Etag %q, Last-Modified %q", etag, modified)
+	}
+
+	if w := get(http.HandlerFunc(renderPage), "/blog/2023/", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("request with the Etag: status %v, want 304", w.Code)
+	}
+	if w := get(http.HandlerFunc(renderPage), "/blog/2023/", "If-Modified-Since", modified); w.Code != http.StatusNotModified {
+		t.Errorf("request with the Last-Modified: status %v, want 304", w.Code)
+	}
+	if w := get(http.HandlerFunc(renderPage), "/blog/2022/"); w.Header().Get("Etag") == etag {
+		t.Error("archives of different years have the same Etag")
+	}
+}
+
+func TestArchiveIndexTemplate(t *testing.T) {
+	setFlag(t, "index-tmpl", "archive.tmpl")
+	writeSite(t, map[string]string{
+		"layout.html":    testLayout,
+		"archive.tmpl":   `<h1>{{.Title}}</h1>{{range .Entries}}<a href="{{.URL}}">{{.Title}}</a> {{.DateText}}{{end}} of {{.Total}}`,
+		"blog/second.md": "---\ntitle: Second\ndate: 2023-01-15\n---\nSecond",
+		"blog/third.md":  "---\ntitle: Third & last\ndate: 2023-06-01\n---\nThird",
+	})
+	if err := updateIndexTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { indexTemplate = nil })
+	loadSite(t)
+
+	body := get(http.HandlerFunc(renderPage), "/blog/2023/").Body.String()
+	want := `<body><h1>2023</h1><a href="/blog/third">Third &amp; last</a> 1 June 2023<a href="/blog/second">Second</a> 15 January 2023 of 2</body>`
+	if !strings.Contains(body, want) {
+		t.Errorf("body %q, want %q", body, want)
+	}
+
+	setFlag(t, "index-tmpl", "missing.tmpl")
+	if err := updateIndexTemplate(); err == nil {
+		t.Error("loading a missing -index-tmpl succeeded")
+	}
+}
+
+func TestArchiveLinksServed(t *testing.T) {
+	for _, policy := range []string{"", "strip", "add"} {
+		setFlag(t, "canonical-slash", policy)
+		testSite(t, map[string]string{
+			"blog/.upublish": `{"default": "latest"}`,
+			"blog/latest.md": "---\ndate: 2023-06-01\n---\nLatest",
+			"blog/older.md":  "---\ndate: 2023-01-15\n---\nOlder",
+		})
+
+		body := get(http.HandlerFunc(renderPage), "/blog/2023/").Body.String()
+		hrefs := hrefPattern.FindAllStringSubmatch(body, -1)
+		if len(hrefs) != 2 {
+			t.Fatalf("-canonical-slash=%v: archive %q does not link both pages", policy, body)
+		}
+		for _, m := range hrefs {
+			if w := get(http.HandlerFunc(renderPage), m[1]); w.Code != 200 {
+				t.Errorf("-canonical-slash=%v: link to %v is %v, want 200", policy, m[1], w.Code)
+			}
+		}
+	}
+}
+
+func TestArchiveDatesFormattedInTimezone(t *testing.T) {
+	setFlag(t, "date-format", "2006-01-02 15:04")
+	setFlag(t, "timezone", "Pacific/Auckland")
+	setupTimezone()
+	t.Cleanup(func() { timezone = nil })
+	testSite(t, map[string]string{
+		"blog/late.md":    "---\ntitle: Late\ndate: 2023-12-31T20:00:00Z\n---\nLate",
+		"blog/undated.md": "Undated",
+	})
+
+	body := get(http.HandlerFunc(renderPage), "/blog/2024/").Body.String()
+	if !strings.Contains(body, `<time datetime="2024-01-01T09:00:00+13:00">2024-01-01 09:00</time>`) {
+		t.Errorf("2024 archive %q does not list the page in Auckland's time", body)
+	}
+	if body := get(http.HandlerFunc(renderPage), "/blog/2023/").Body.String(); strings.Contains(body, "Late") {
+		t.Errorf("2023 archive %q lists a page dated 2024 in Auckland", body)
+	}
+
+	if d := pageDate(&ContentFile{}); !d.IsZero() {
+		t.Errorf("pageDate of an undated page = %v", d)
+	}
+}
+
+func TestArchivePagination(t *testing.T) {
+	setFlag(t, "page-size", "2")
+	testSite(t, map[string]string{
+		"blog/a.md": "---\ntitle: A\ndate: 2023-03-01\n---\nA",
+		"blog/b.md": "---\ntitle: B\ndate: 2023-04-01\n---\nB",
+		"blog/c.md": "---\ntitle: C\ndate: 2023-05-01\n---\nC",
+	})
+
+	var data archiveData
+	w := get(http.HandlerFunc(renderPage), "/blog/2023/?format=json&page=2")
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Total != 3 || data.Page != 2 || data.PageSize != 2 || len(data.Entries) != 1 || data.Entries[0].Title != "A" {
+		t.Errorf("page 2: %+v", data)
+	}
+	if data.PrevURL != "/blog/2023/?page=1" || data.NextURL != "" {
+		t.Errorf("page 2 links to %q and %q", data.PrevURL, data.NextURL)
+	}
+
+	body := get(http.HandlerFunc(renderPage), "/blog/2023/").Body.String()
+	if !strings.Contains(body, `<a href="/blog/2023/?page=2" rel="next">Older</a>`) || strings.Contains(body, ">A<") {
+		t.Errorf("page 1 %q", body)
+	}
+}
+
+func TestRecursiveArchives(t *testing.T) {
+	testSite(t, map[string]string{
+		"blog/top.md":          "---\ntitle: Top\ndate: 2023-06-01\n---\nTop",
+		"blog/posts/nested.md": "---\ntitle: Nested\ndate: 2023-05-01\n---\nNested",
+		"blog/posts/.upublish": `{"default": "nested"}`,
+		"docs/.upublish":       `{"recursive": true}`,
+		"docs/guides/how.md":   "---\ntitle: How\ndate: 2023-04-01\n---\nHow",
+	})
+
+	body := get(http.HandlerFunc(renderPage), "/blog/2023/").Body.String()
+	if !strings.Contains(body, "Top") || strings.Contains(body, "Nested") {
+		t.Errorf("archive without -recursive-archives %q", body)
+	}
+	if body := get(http.HandlerFunc(renderPage), "/docs/2023/").Body.String(); !strings.Contains(body, `<a href="/docs/guides/how">How</a>`) {
+		t.Errorf("archive of a recursive directory %q", body)
+	}
+
+	setFlag(t, "recursive-archives", "true")
+	body = get(http.HandlerFunc(renderPage), "/blog/2023/").Body.String()
+	top, nested := strings.Index(body, "Top"), strings.Index(body, `<a href="/blog/posts/">Nested</a>`)
+	if top < 0 || nested < 0 || top > nested {
+		t.Errorf("archive with -recursive-archives %q, want Top then Nested", body)
+	}
+}
+
+func TestRecursiveArchivesHideProtectedPages(t *testing.T) {
+	testSite(t, map[string]string{
+		"post.md":         "---\ntitle: Post\ndate: 2023-06-01\n---\nPost",
+		"private/plan.md": "---\ntitle: Plan\ndate: 2023-05-01\n---\nPlan",
+	})
+	setFlag(t, "recursive-archives", "true")
+	setFlag(t, "auth-prefix", "/private/")
+	setFlag(t, "auth-user", "admin")
+	setFlag(t, "auth-pass", "secret")
+	h := testHandler()
+
+	for _, u := range []string{"/2023/", "/2023/?format=json"} {
+		body := get(h, u).Body.String()
+		if !strings.Contains(body, "Post") || strings.Contains(body, "Plan") {
+			t.Errorf("%v %q, want Post without the protected Plan", u, body)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/private/2023/", nil)
+	r.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), "Plan") {
+		t.Errorf("protected archive %q does not list Plan", w.Body.String())
+	}
+}
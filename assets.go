@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// assets maps the paths of the files in the public directory to their
+// fingerprinted paths, which include a hash of their content, and
+// fingerprinted maps them back.
+var assets map[string]string
+var fingerprinted map[string]string
+var assetsLock sync.RWMutex
+
+// updateAssets fingerprints every file in the public directory.
+func updateAssets() error {
+	public := filepath.Join(root, *optStaticDir)
+	a := make(map[string]string)
+	f := make(map[string]string)
+
+	err := walkFiles(public, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == public {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		b, err := readFile(p)
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(public, p)
+		name := filepath.ToSlash(rel)
+		ext := path.Ext(name)
+		fp := fmt.Sprintf("%v.%x%v", strings.TrimSuffix(name, ext), hash(b)[:4], ext)
+
+		a[name] = fp
+		f[fp] = name
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	assetsLock.Lock()
+	assets, fingerprinted = a, f
+	assetsLock.Unlock()
+	return nil
+}
+
+// assetToken returns the file named by an {{asset "path"}} placeholder.
+func assetToken(token string) (string, bool) {
+	if !strings.HasPrefix(token, "asset ") {
+		return "", false
+	}
+
+	name, err := strconv.Unquote(strings.TrimSpace(token[len("asset "):]))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimPrefix(path.Clean("/"+name), "/"), true
+}
+
+func assetURL(name string) (string, error) {
+	assetsLock.RLock()
+	defer assetsLock.RUnlock()
+
+	fp, ok := assets[name]
+	if !ok {
+		return "", fmt.Errorf("unknown asset '%v'", name)
+	}
+	return "/public/" + fp, nil
+}
+
+// serveAssets serves the fingerprinted paths of files in the public
+// directory as the files themselves. Their content can not change, so
+// they are cached for as long as possible.
+func serveAssets(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assetsLock.RLock()
+		name, ok := fingerprinted[strings.TrimPrefix(r.URL.Path, "/")]
+		assetsLock.RUnlock()
+
+		if ok {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			r.URL.Path = "/" + name
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"net/http"
+	"strings"
+)
+
+var optAuthPrefix = flag.String("auth-prefix", "", "path prefix that requires HTTP basic authentication")
+var optAuthUser = flag.String("auth-user", "", "user name for -auth-prefix")
+var optAuthPass = flag.String("auth-pass", "", "password for -auth-prefix")
+
+func basicAuth(h http.Handler) http.Handler {
+	if *optAuthPrefix == "" {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if protected(r.URL.Path) && !authorized(w, r) {
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// protected reports whether the URL path p is beneath -auth-prefix, so is
+// left out of listings anyone can see, such as search results.
+func protected(p string) bool {
+	return *optAuthPrefix != "" && strings.HasPrefix(p, *optAuthPrefix)
+}
+
+// authorized checks the request's basic credentials, answering with a 401
+// and returning false if they are missing or wrong.
+func authorized(w http.ResponseWriter, r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+
+	// compare both so the time taken doesn't reveal which was wrong
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(*optAuthUser)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(*optAuthPass)) == 1
+
+	if ok && userOK && passOK && *optAuthUser != "" {
+		return true
+	}
+
+	w.Header().Set("WWW-Authenticate", `Basic realm="upublish", charset="UTF-8"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return false
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	setFlag(t, "auth-prefix", "/private/")
+	setFlag(t, "auth-user", "admin")
+	setFlag(t, "auth-pass", "secret")
+	testSite(t, map[string]string{"private/plans.md": "Plans", "about.md": "About"})
+	h := testHandler()
+
+	for _, test := range []struct {
+		user, pass string
+		set        bool
+		want       int
+	}{
+		{"", "", false, http.StatusUnauthorized},
+		{"admin", "wrong", true, http.StatusUnauthorized},
+		{"someone", "secret", true, http.StatusUnauthorized},
+		{"admin", "secret", true, http.StatusOK},
+	} {
+		r := httptest.NewRequest("GET", "/private/plans", nil)
+		if test.set {
+			r.SetBasicAuth(test.user, test.pass)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.want {
+			t.Errorf("%q:%q: status %v, want %v", test.user, test.pass, w.Code, test.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%q:%q: 401 without WWW-Authenticate", test.user, test.pass)
+		}
+	}
+
+	if w := get(h, "/about"); w.Code != http.StatusOK {
+		t.Errorf("/about outside the prefix: status %v, want 200", w.Code)
+	}
+}
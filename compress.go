@@ -0,0 +1,115 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressible are the extensions of static files worth compressing;
+// images, fonts and archives are compressed already.
+var compressible = map[string]bool{
+	".css": true, ".js": true, ".mjs": true, ".json": true, ".map": true,
+	".svg": true, ".txt": true, ".html": true, ".xml": true,
+}
+
+var precompressedExt = map[string]string{"br": ".br", "gzip": ".gz"}
+
+// compressStatic compresses compressible files in dir for clients which
+// accept it, serving a precompressed sibling such as site.css.br or
+// site.css.gz when there is one.
+func compressStatic(dir string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ext := path.Ext(r.URL.Path)
+		if !compressible[ext] {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		etag, ok := fileETag(name)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// the compressed response is a different representation of the file
+		w.Header().Set("Etag", strings.TrimSuffix(etag, `"`)+"-"+encoding+`"`)
+
+		if f, err := openFile(name + precompressedExt[encoding]); err == nil {
+			defer f.Close()
+
+			info, err := f.Stat()
+			if rs, ok := f.(io.ReadSeeker); ok && err == nil && !info.IsDir() {
+				w.Header().Set("Content-Encoding", encoding)
+				w.Header().Set("Content-Type", mime.TypeByExtension(ext))
+				http.ServeContent(w, r, path.Base(r.URL.Path), info.ModTime(), rs)
+				return
+			}
+		}
+
+		r.Header.Del("Range")
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		h.ServeHTTP(cw, r)
+		cw.Close()
+	})
+}
+
+// compressWriter compresses successful responses as they are written.
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding string
+	w        io.WriteCloser
+	status   int
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.status != 0 {
+		return
+	}
+	cw.status = statusCode
+
+	if statusCode == http.StatusOK {
+		cw.Header().Del("Content-Length")
+		cw.Header().Set("Content-Encoding", cw.encoding)
+
+		if cw.encoding == "br" {
+			cw.w = brotli.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.w = gzip.NewWriter(cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.w != nil {
+		return cw.w.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *compressWriter) Close() {
+	if cw.w != nil {
+		cw.w.Close()
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestStaticTextCompressed(t *testing.T) {
+	css := strings.Repeat("body { color: black; }\n", 50)
+	testSite(t, map[string]string{
+		".public/style.css":    css,
+		".public/style.css.br": "precompressed",
+		".public/app.js":       "console.log('app')",
+		".public/photo.png":    "not really a png",
+	})
+	h := testHandler()
+
+	w := get(h, "/public/app.js", "Accept-Encoding", "gzip")
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("app.js Content-Encoding %q, want gzip", ce)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(gz); err != nil || string(b) != "console.log('app')" {
+		t.Errorf("app.js decompressed to %q, %v", b, err)
+	}
+	if etag := w.Header().Get("Etag"); !strings.HasSuffix(etag, `-gzip"`) {
+		t.Errorf("gzipped app.js Etag %q", etag)
+	}
+
+	w = get(h, "/public/style.css", "Accept-Encoding", "br")
+	if ce := w.Header().Get("Content-Encoding"); ce != "br" || w.Body.String() != "precompressed" {
+		t.Errorf("style.css Content-Encoding %q, body %q; want the precompressed file", ce, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("precompressed style.css Content-Type %q", ct)
+	}
+
+	w = get(h, "/public/photo.png", "Accept-Encoding", "gzip, br")
+	if ce := w.Header().Get("Content-Encoding"); ce != "" {
+		t.Errorf("photo.png Content-Encoding %q", ce)
+	}
+
+	w = get(h, "/public/style.css")
+	if ce := w.Header().Get("Content-Encoding"); ce != "" || w.Body.String() != css {
+		t.Errorf("style.css without Accept-Encoding: Content-Encoding %q", ce)
+	}
+}
+
+func TestStaticBrotli(t *testing.T) {
+	testSite(t, map[string]string{".public/app.js": "console.log('app')"})
+
+	w := get(testHandler(), "/public/app.js", "Accept-Encoding", "br")
+	if ce := w.Header().Get("Content-Encoding"); ce != "br" {
+		t.Fatalf("Content-Encoding %q, want br", ce)
+	}
+	if b, err := io.ReadAll(brotli.NewReader(w.Body)); err != nil || string(b) != "console.log('app')" {
+		t.Errorf("decompressed to %q, %v", b, err)
+	}
+}
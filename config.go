@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var optConfig = flag.String("config", "", "path of a JSON file of option values, keyed by option name")
+
+// cmdline and env record the options given on the command line and in
+// the environment, which take precedence over the config file in that
+// order.
+var cmdline map[string]bool
+var env map[string]bool
+
+// configured records the options set from the config file.
+var configured map[string]bool
+
+// loadOptions applies the environment and then the config file to the
+// options not given on the command line.
+func loadOptions() error {
+	cmdline = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		cmdline[f.Name] = true
+	})
+
+	if err := loadEnv(); err != nil {
+		return err
+	}
+
+	return loadConfig()
+}
+
+// envName is the environment variable for an option, e.g. UPUBLISH_ADDR
+// for -addr or UPUBLISH_LOG_FORMAT for -log-format.
+func envName(name string) string {
+	return "UPUBLISH_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+func loadEnv() error {
+	env = make(map[string]bool)
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if !ok || cmdline[f.Name] || err != nil {
+			return
+		}
+
+		if err = flag.Set(f.Name, v); err != nil {
+			err = fmt.Errorf("Invalid value for %v: %v", envName(f.Name), err)
+		}
+		env[f.Name] = true
+	})
+
+	return err
+}
+
+// reloadable are the options only read while the pages are loaded, which
+// a reload of the config file is serialized with. The others are read as
+// requests are served, so are left as they are until a restart.
+var reloadable = map[string]bool{
+	"errors": true, "external-links-newtab": true, "footer-md": true, "header-md": true,
+	"heading-anchors": true, "img-dimensions": true, "index-tmpl": true, "md-footnotes": true,
+	"md-smartypants": true, "md-tables": true, "md-tasklists": true, "partials": true,
+	"reading-code": true, "reading-wpm": true, "related": true, "renderer": true,
+	"sanitize": true, "workers": true,
+}
+
+// loadConfig sets each option in the config file that was not given on
+// the command line or in the environment.
+func loadConfig() error {
+	configured = make(map[string]bool)
+
+	values, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	for name, s := range values {
+		if cmdline[name] || env[name] {
+			continue
+		}
+
+		if err = flag.Set(name, s); err != nil {
+			return fmt.Errorf("Invalid value for option '%v' in config file '%v': %v", name, *optConfig, err)
+		}
+		configured[name] = true
+	}
+
+	return nil
+}
+
+// readConfig returns the option values in the config file, keyed by
+// option name.
+func readConfig() (map[string]string, error) {
+	options := make(map[string]string)
+	if *optConfig == "" {
+		return options, nil
+	}
+
+	b, err := ioutil.ReadFile(*optConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	if err = json.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("Failed to parse config file '%v': %v", *optConfig, err)
+	}
+
+	for name, v := range values {
+		if name == "config" || flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("Unknown option '%v' in config file '%v'", name, *optConfig)
+		}
+
+		switch v := v.(type) {
+		case string:
+			options[name] = v
+		case bool:
+			options[name] = strconv.FormatBool(v)
+		case float64:
+			options[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("Invalid value for option '%v' in config file '%v'", name, *optConfig)
+		}
+	}
+
+	return options, nil
+}
+
+// reloadConfig reads the config file again, with options no longer in it
+// returning to their defaults, and logs the options which changed. Only
+// the reloadable options are changed; the others are logged as needing a
+// restart. Nothing is changed if the file is invalid.
+func reloadConfig() error {
+	values, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	want := make(map[string]string)
+	for name := range configured {
+		want[name] = flag.Lookup(name).DefValue
+	}
+	for name, s := range values {
+		if !cmdline[name] && !env[name] {
+			want[name] = s
+		}
+	}
+
+	changed := make(map[string]string)
+	for name, s := range want {
+		f := flag.Lookup(name)
+		v, err := optionValue(f, s)
+		if err != nil {
+			return fmt.Errorf("Invalid value for option '%v' in config file '%v': %v", name, *optConfig, err)
+		}
+
+		if v == f.Value.String() {
+			continue
+		}
+		if !reloadable[name] {
+			log.Printf("Option %v changed to '%v'; restart to apply\n", name, v)
+			continue
+		}
+		changed[name] = v
+	}
+
+	if r, ok := changed["renderer"]; ok && !validRenderer(r) {
+		return fmt.Errorf("Unknown renderer '%v'", r)
+	}
+
+	loadLock.Lock()
+	defer loadLock.Unlock()
+
+	configured = make(map[string]bool)
+	for name := range values {
+		if !cmdline[name] && !env[name] {
+			configured[name] = true
+		}
+	}
+
+	for name, v := range changed {
+		log.Printf("Option %v changed from '%v' to '%v'\n", name, flag.Lookup(name).Value, v)
+		flag.Set(name, v)
+	}
+	return nil
+}
+
+// optionValue returns s as the option would show it once set, without
+// setting it.
+func optionValue(f *flag.Flag, s string) (string, error) {
+	v := reflect.New(reflect.TypeOf(f.Value).Elem()).Interface().(flag.Value)
+	if err := v.Set(s); err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
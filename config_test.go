@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeConfig writes a config file and makes it the -config.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, "config", p)
+
+	previous := configured
+	t.Cleanup(func() { configured = previous })
+	return p
+}
+
+func TestReloadConfigChangesLoadOptions(t *testing.T) {
+	setFlag(t, "related", "5")
+	setFlag(t, "cache-control", "")
+	p := writeConfig(t, `{"related": 2, "cache-control": "no-cache"}`)
+	logged := captureLog(t)
+
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if v := *optRelated; v != 2 {
+		t.Errorf("-related %v after a reload, want 2", v)
+	}
+	if v := *optCacheControl; v != "" {
+		t.Errorf("-cache-control %q after a reload, want it unchanged until a restart", v)
+	}
+	if !strings.Contains(logged.String(), "cache-control") || !strings.Contains(logged.String(), "restart") {
+		t.Errorf("log %q does not say -cache-control needs a restart", logged)
+	}
+
+	// removed from the file, so back to the default
+	if err := os.WriteFile(p, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if v := *optRelated; v != 5 {
+		t.Errorf("-related %v once removed from the file, want the default 5", v)
+	}
+}
+
+func TestReloadConfigKeepsOptionsWhenInvalid(t *testing.T) {
+	setFlag(t, "related", "5")
+	setFlag(t, "renderer", "markdown")
+
+	for _, config := range []string{`{"related": 2, "renderer": "nonsense"}`, `{"related": "two"}`, `{"related": 2, "nonsense": 1}`, `{`} {
+		writeConfig(t, config)
+		if err := reloadConfig(); err == nil {
+			t.Errorf("reloading %v succeeded", config)
+		}
+		if v := *optRelated; v != 5 {
+			t.Errorf("-related %v after reloading %v, want it unchanged", v, config)
+		}
+	}
+}
+
+func TestReloadConfigWhileServing(t *testing.T) {
+	setFlag(t, "related", "5")
+	testSite(t, map[string]string{"about.md": "About"})
+	h := testHandler()
+	p := writeConfig(t, `{"related": 2, "cache-control": "no-cache", "minify": true}`)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if w := get(h, "/about"); w.Code != http.StatusOK {
+					t.Errorf("status %v while reloading, want 200", w.Code)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := reloadConfig(); err != nil {
+			t.Fatal(err)
+		}
+		reload()
+		if err := os.WriteFile(p, []byte(`{}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
+
+// setSources records which options were given on the command line and in
+// the environment, for the rest of the test.
+func setSources(t *testing.T, flags, vars []string) {
+	previousCmdline, previousEnv := cmdline, env
+	t.Cleanup(func() { cmdline, env = previousCmdline, previousEnv })
+
+	cmdline, env = make(map[string]bool), make(map[string]bool)
+	for _, name := range flags {
+		cmdline[name] = true
+	}
+	for _, name := range vars {
+		env[name] = true
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	setFlag(t, "related", "7")
+	setFlag(t, "reading-wpm", "200")
+	setFlag(t, "date-format", "January 2, 2006")
+	setSources(t, []string{"related"}, nil)
+	writeConfig(t, `{"related": 2, "reading-wpm": 100, "date-format": "2006-01-02", "md-tables": false}`)
+	setFlag(t, "md-tables", "true")
+
+	if err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if *optRelated != 7 {
+		t.Errorf("-related %v, want the command line's 7", *optRelated)
+	}
+	if *optReadingWPM != 100 || *optDateFormat != "2006-01-02" || *optMdTables {
+		t.Errorf("-reading-wpm %v, -date-format %q, -md-tables %v; want the config file's", *optReadingWPM, *optDateFormat, *optMdTables)
+	}
+	if configured["related"] || !configured["reading-wpm"] {
+		t.Errorf("configured %v", configured)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	setSources(t, nil, nil)
+	// an invalid number still sets the option, to 0
+	setFlag(t, "related", "5")
+
+	for config, want := range map[string]string{
+		`{"nonsense": 1}`:      "Unknown option 'nonsense'",
+		`{"config": "x.json"}`: "Unknown option 'config'",
+		`{"related": "two"}`:   "Invalid value for option 'related'",
+		`{"related": [2]}`:     "Invalid value for option 'related'",
+		`{`:                    "Failed to parse config file",
+	} {
+		writeConfig(t, config)
+		if err := loadConfig(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("loading %v: error %v, want %q", config, err, want)
+		}
+	}
+}
+
+func TestEnvironmentOptions(t *testing.T) {
+	setFlag(t, "related", "7")
+	setFlag(t, "reading-wpm", "200")
+	setSources(t, []string{"related"}, nil)
+	t.Setenv("UPUBLISH_RELATED", "3")
+	t.Setenv("UPUBLISH_READING_WPM", "150")
+
+	if err := loadEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if *optRelated != 7 {
+		t.Errorf("-related %v, want the command line's 7", *optRelated)
+	}
+	if *optReadingWPM != 150 {
+		t.Errorf("-reading-wpm %v, want the environment's 150", *optReadingWPM)
+	}
+	if env["related"] || !env["reading-wpm"] {
+		t.Errorf("env %v", env)
+	}
+
+	// the environment takes precedence over the config file
+	writeConfig(t, `{"reading-wpm": 100}`)
+	if err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if *optReadingWPM != 150 {
+		t.Errorf("-reading-wpm %v after loading the config file, want the environment's 150", *optReadingWPM)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for name, want := range map[string]string{"addr": "UPUBLISH_ADDR", "log-format": "UPUBLISH_LOG_FORMAT", "md-tasklists": "UPUBLISH_MD_TASKLISTS"} {
+		if got := envName(name); got != want {
+			t.Errorf("envName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestReloadableOptionsExist(t *testing.T) {
+	for name := range reloadable {
+		if flag.Lookup(name) == nil {
+			t.Errorf("reloadable option %q is not a flag", name)
+		}
+	}
+}
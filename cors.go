@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+)
+
+var optCORSOrigins = flag.String("cors-origins", "", "comma separated origins allowed to fetch the JSON views of pages, or * for any")
+var optCORSMethods = flag.String("cors-methods", "GET, HEAD", "methods allowed in answers to CORS preflight requests")
+
+// corsOrigin returns the value of Access-Control-Allow-Origin for the
+// request, if its Origin is allowed by -cors-origins.
+func corsOrigin(r *http.Request) (string, bool) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || *optCORSOrigins == "" {
+		return "", false
+	}
+
+	for _, o := range strings.Split(*optCORSOrigins, ",") {
+		o = strings.TrimSpace(o)
+		if o == "*" {
+			return "*", true
+		}
+		if strings.EqualFold(o, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// setCORS allows JSON responses to be read by the allowed origins.
+func setCORS(w http.ResponseWriter, r *http.Request) {
+	if *optCORSOrigins == "" {
+		return
+	}
+
+	w.Header().Add("Vary", "Origin")
+	if o, ok := corsOrigin(r); ok {
+		w.Header().Set("Access-Control-Allow-Origin", o)
+	}
+}
+
+// corsPreflight answers CORS preflight requests for the JSON views of
+// pages, archives and search results, asked for with ?format=json, with
+// 204. Preflight requests carry no credentials, so are answered before
+// basicAuth. Those for any other route are passed on to it.
+func corsPreflight(mux *http.ServeMux, h http.Handler) http.Handler {
+	if *optCORSOrigins == "" {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" || !jsonRoute(mux, r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Vary", "Origin")
+		if o, ok := corsOrigin(r); ok {
+			w.Header().Set("Access-Control-Allow-Origin", o)
+			w.Header().Set("Access-Control-Allow-Methods", *optCORSMethods)
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// jsonRoute reports whether r is for the JSON view of a page, archive or
+// search results.
+func jsonRoute(mux *http.ServeMux, r *http.Request) bool {
+	if r.URL.Query().Get("format") != "json" {
+		return false
+	}
+
+	_, pattern := mux.Handler(r)
+	return pattern == "/" || pattern == "/search"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// preflight sends a CORS preflight request for u through h.
+func preflight(h http.Handler, u, origin string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("OPTIONS", u, nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	r.Header.Set("Access-Control-Request-Headers", "X-Requested-With")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCORSPreflightForJSONViews(t *testing.T) {
+	setFlag(t, "cors-origins", "https://app.example.com")
+	testSite(t, map[string]string{"about.md": "About"})
+	h := testHandler()
+
+	for _, u := range []string{"/about?format=json", "/search?q=about&format=json"} {
+		w := preflight(h, u, "https://app.example.com")
+		if w.Code != http.StatusNoContent {
+			t.Errorf("preflight for %v: status %v, want 204", u, w.Code)
+		}
+		if o := w.Header().Get("Access-Control-Allow-Origin"); o != "https://app.example.com" {
+			t.Errorf("preflight for %v: Access-Control-Allow-Origin %q", u, o)
+		}
+		if m := w.Header().Get("Access-Control-Allow-Methods"); m != "GET, HEAD" {
+			t.Errorf("preflight for %v: Access-Control-Allow-Methods %q", u, m)
+		}
+		if hs := w.Header().Get("Access-Control-Allow-Headers"); hs != "X-Requested-With" {
+			t.Errorf("preflight for %v: Access-Control-Allow-Headers %q", u, hs)
+		}
+	}
+
+	w := preflight(h, "/about?format=json", "https://evil.example.com")
+	if o := w.Header().Get("Access-Control-Allow-Origin"); o != "" {
+		t.Errorf("preflight from another origin allowed %q", o)
+	}
+}
+
+func TestCORSPreflightNotForOtherRoutes(t *testing.T) {
+	setFlag(t, "cors-origins", "*")
+	setFlag(t, "auth-user", "admin")
+	setFlag(t, "auth-pass", "secret")
+	testSite(t, map[string]string{"about.md": "About"})
+	h := testHandler()
+
+	for _, u := range []string{"/about", "/admin/purge", "/admin/purge?format=json", "/healthz?format=json"} {
+		w := preflight(h, u, "https://app.example.com")
+		if w.Code == http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("preflight for %v answered with %v and Access-Control-Allow-Origin %q", u, w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+}
+
+func TestCORSHeadersOnlyOnJSON(t *testing.T) {
+	setFlag(t, "cors-origins", "https://app.example.com")
+	testSite(t, map[string]string{"about.md": "About"})
+	h := testHandler()
+
+	w := get(h, "/about?format=json", "Origin", "https://app.example.com")
+	if o := w.Header().Get("Access-Control-Allow-Origin"); o != "https://app.example.com" {
+		t.Errorf("JSON view: Access-Control-Allow-Origin %q", o)
+	}
+
+	w = get(h, "/about", "Origin", "https://app.example.com")
+	if o := w.Header().Get("Access-Control-Allow-Origin"); o != "" {
+		t.Errorf("HTML page: Access-Control-Allow-Origin %q", o)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var optDebug = flag.Bool("debug", false, "serve the cached pages at /debug/cache, requiring the -auth-user credentials if set")
+
+// loadedAt is when the tree being served was read.
+var loadedAt time.Time
+
+type debugPage struct {
+	Path       string    `json:"path"`
+	Size       int       `json:"size"`
+	GzipSize   int       `json:"gzipSize,omitempty"`
+	BrotliSize int       `json:"brotliSize,omitempty"`
+	Hash       string    `json:"hash"`
+	Modified   time.Time `json:"modified"`
+	Draft      bool      `json:"draft,omitempty"`
+}
+
+type debugCache struct {
+	LoadedAt     time.Time   `json:"loadedAt"`
+	Pages        []debugPage `json:"pages"`
+	Assets       int         `json:"assets"`
+	SearchPages  int         `json:"searchPages"`
+	SearchTokens int         `json:"searchTokens"`
+}
+
+func setupDebug(mux *http.ServeMux) {
+	if !*optDebug {
+		return
+	}
+
+	mux.HandleFunc("/debug/cache", func(w http.ResponseWriter, r *http.Request) {
+		if *optAuthUser != "" && !authorized(w, r) {
+			return
+		}
+
+		treeLock.RLock()
+		c := debugCache{LoadedAt: loadedAt, Pages: make([]debugPage, 0)}
+		d := tree
+		treeLock.RUnlock()
+
+		d.Walk(func(p string, dir *Dir) {
+			for _, n := range dir.FileNames() {
+				cf := dir.Files[n]
+				c.Pages = append(c.Pages, debugPage{Path: pageURL(dir, p, n), Size: len(cf.Content),
+					GzipSize: len(cf.GzipContent), BrotliSize: len(cf.BrotliContent),
+					Hash: fmt.Sprintf("%x", cf.Hash), Modified: cf.ModTime, Draft: cf.Draft})
+			}
+		})
+
+		assetsLock.RLock()
+		c.Assets = len(assets)
+		assetsLock.RUnlock()
+
+		searchLock.RLock()
+		if search != nil {
+			c.SearchPages, c.SearchTokens = len(search.pages), len(search.tokens)
+		}
+		searchLock.RUnlock()
+
+		b, _ := json.MarshalIndent(c, "", "  ")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(b)
+	})
+}
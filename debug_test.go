@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugCache(t *testing.T) {
+	testSite(t, map[string]string{"about.md": strings.Repeat("About. ", 300), "blog/post.md": "Post"})
+	if w := get(testHandler(), "/debug/cache"); w.Code != http.StatusNotFound {
+		t.Errorf("/debug/cache without -debug: status %v, want 404", w.Code)
+	}
+
+	setFlag(t, "debug", "true")
+	setFlag(t, "auth-user", "admin")
+	setFlag(t, "auth-pass", "secret")
+	h := testHandler()
+
+	if w := get(h, "/debug/cache"); w.Code != http.StatusUnauthorized {
+		t.Errorf("/debug/cache without credentials: status %v, want 401", w.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/debug/cache", nil)
+	r.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var c debugCache
+	if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.LoadedAt.IsZero() || len(c.Pages) != 2 || c.SearchPages != 2 {
+		t.Fatalf("cache %+v", c)
+	}
+	for _, p := range c.Pages {
+		if p.Path == "/about" && (p.Size == 0 || p.GzipSize == 0 || p.BrotliSize == 0 || p.Hash == "") {
+			t.Errorf("/about listed as %+v", p)
+		}
+	}
+	if c.Pages[0].Path != "/about" || c.Pages[1].Path != "/blog/post" {
+		t.Errorf("pages %+v", c.Pages)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+)
+
+var optDefault = flag.String("default", "index", "name of the page served for a directory, unless its .upublish file names another")
+
+// DirConfigFilename is the file in a directory holding its settings, such
+// as {"default": "latest", "recursive": true}.
+var DirConfigFilename = ".upublish"
+
+type dirConfig struct {
+	Default   string `json:"default"`
+	Recursive bool   `json:"recursive"`
+}
+
+func readDirConfig(dir string) (dirConfig, error) {
+	var c dirConfig
+
+	b, err := readFile(filepath.Join(dir, DirConfigFilename))
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// defaultName is the name of the page served for the directory itself.
+func (d *Dir) defaultName() string {
+	if d != nil && d.Default != "" {
+		return d.Default
+	}
+	return *optDefault
+}
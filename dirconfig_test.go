@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryDefaultPage(t *testing.T) {
+	setFlag(t, "default", "home")
+	d := testSite(t, map[string]string{
+		"home.md":        "Home",
+		"index.md":       "Index",
+		"blog/.upublish": `{"default": "latest"}`,
+		"blog/latest.md": "Latest",
+		"blog/home.md":   "Blog home",
+		"docs/home.md":   "Docs home",
+	})
+
+	for u, want := range map[string]string{"/": "Home", "/blog/": "Latest", "/docs/": "Docs home", "/blog/home": "Blog home"} {
+		if body := get(http.HandlerFunc(renderPage), u).Body.String(); !strings.Contains(body, want) {
+			t.Errorf("%v: body %q, want %q", u, body, want)
+		}
+	}
+	if d.Directories["blog"].defaultName() != "latest" || d.Directories["docs"].defaultName() != "home" {
+		t.Error("defaultName does not follow .upublish and -default")
+	}
+	if u := linkURL(d.Directories["blog"], "/blog/", "latest"); u != "/blog/" {
+		t.Errorf("linkURL of the default page = %q, want /blog/", u)
+	}
+}
+
+func TestDirConfigInvalid(t *testing.T) {
+	writeSite(t, map[string]string{".upublish": `{"default": `})
+	if _, err := readDirConfig(root); err == nil {
+		t.Error("reading an invalid .upublish succeeded")
+	}
+}
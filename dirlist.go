@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var optDirListing = flag.Bool("dir-listing", false, "list the files in directories of the 'public' directory which have no index.html")
+
+// dirListing answers requests for directories in dir without an
+// index.html, either with 404 or, with -dir-listing, a list of their files
+// in the root layout.
+func dirListing(dir string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if info, err := statFile(name); err != nil || !info.IsDir() {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if _, err := statFile(filepath.Join(name, "index.html")); err == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !*optDirListing {
+			writeError(w, r, getTree(), 404, "Not Found")
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			// http.FileServer redirects to the path with a slash
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		infos, err := readDir(name)
+		if err != nil {
+			writeError(w, r, getTree(), 500, "Could not list the directory! "+err.Error())
+			return
+		}
+
+		title := path.Join("/public", r.URL.Path) + "/"
+		b := &strings.Builder{}
+		b.WriteString("<h2>" + html.EscapeString(title) + "</h2>\n")
+		b.WriteString("<ul class=\"listing\">\n")
+		if r.URL.Path != "/" {
+			b.WriteString("<li><a href=\"../\">../</a></li>\n")
+		}
+		for _, info := range infos {
+			n := info.Name()
+			if strings.HasPrefix(n, ".") {
+				continue
+			}
+			if info.IsDir() {
+				n += "/"
+			}
+			b.WriteString("<li><a href=\"" + html.EscapeString((&url.URL{Path: n}).String()) + "\">" + html.EscapeString(n) + "</a>")
+			if !info.IsDir() {
+				fmt.Fprintf(b, " <span class=\"size\">%v</span>", formatSize(info.Size()))
+			}
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</ul>\n")
+
+		cf := &ContentFile{Content: []byte(b.String())}
+		cf.Title = title
+		cf.CacheControl = "no-cache"
+		write(w, r, 200, cf, getTree().Layout)
+	})
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%v bytes", n)
+}
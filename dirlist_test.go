@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDirListing(t *testing.T) {
+	testSite(t, map[string]string{
+		".public/files/notes.txt":     "notes",
+		".public/files/my report.pdf": strings.Repeat("x", 2048),
+		".public/files/.hidden":       "hidden",
+		".public/files/old/a.txt":     "a",
+		".public/site/index.html":     "<p>site</p>",
+	})
+	h := testHandler()
+
+	if w := get(h, "/public/files/"); w.Code != http.StatusNotFound {
+		t.Errorf("/public/files/ without -dir-listing: status %v, want 404", w.Code)
+	}
+
+	setFlag(t, "dir-listing", "true")
+	w := get(h, "/public/files/")
+	if w.Code != http.StatusOK {
+		t.Fatalf("/public/files/: status %v, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"<title>/public/files/</title>",
+		`<li><a href="../">../</a></li>`,
+		`<li><a href="my%20report.pdf">my report.pdf</a> <span class="size">2.0 KB</span></li>`,
+		`<li><a href="notes.txt">notes.txt</a> <span class="size">5 bytes</span></li>`,
+		`<li><a href="old/">old/</a></li>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("listing %q lacks %q", body, want)
+		}
+	}
+	if strings.Contains(body, ".hidden") {
+		t.Errorf("listing %q shows hidden files", body)
+	}
+
+	if w := get(h, "/public/site/"); w.Body.String() != "<p>site</p>" {
+		t.Errorf("/public/site/ body %q, want its index.html", w.Body.String())
+	}
+	if w := get(h, "/public/files"); w.Code != http.StatusMovedPermanently {
+		t.Errorf("/public/files: status %v, want a redirect", w.Code)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+)
+
+var optDownloadsDir = flag.String("downloads", "", "path of a directory of files served as downloads at /downloads/")
+
+func setupDownloads(mux *http.ServeMux) {
+	if *optDownloadsDir == "" {
+		return
+	}
+
+	dir := http.FS(subFS(filepath.Join(root, *optDownloadsDir)))
+
+	mux.Handle("/downloads/", staticCacheControl(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path[len("/downloads/"):])
+
+		f, err := dir.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Name()}))
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})))
+}
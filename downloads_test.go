@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDownloadRange(t *testing.T) {
+	setFlag(t, "downloads", "downloads")
+	testSite(t, map[string]string{
+		"downloads/notes.txt": "0123456789",
+		".public/data.txt":    "abcdefghij",
+	})
+	h := testHandler()
+
+	for u, want := range map[string]string{"/downloads/notes.txt": "0123", "/public/data.txt": "abcd"} {
+		w := get(h, u, "Range", "bytes=0-3")
+		if w.Code != http.StatusPartialContent {
+			t.Errorf("%v: status %v, want 206", u, w.Code)
+		}
+		if body := w.Body.String(); body != want {
+			t.Errorf("%v: body %q, want %q", u, body, want)
+		}
+		if cr := w.Header().Get("Content-Range"); cr != "bytes 0-3/10" {
+			t.Errorf("%v: Content-Range %q", u, cr)
+		}
+	}
+
+	w := get(h, "/downloads/notes.txt")
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename=notes.txt` {
+		t.Errorf("Content-Disposition %q", cd)
+	}
+	if w := get(h, "/downloads/missing.txt"); w.Code != http.StatusNotFound {
+		t.Errorf("missing download: status %v, want 404", w.Code)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build embed
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// The site to embed is copied to the site directory before building, e.g.
+// cp -r /srv/http/mysite site && go build -tags embed
+//
+//go:embed all:site
+var embeddedFiles embed.FS
+
+func init() {
+	embedded, _ = fs.Sub(embeddedFiles, "site")
+}
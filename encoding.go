@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+)
+
+var optGzipMinBytes = flag.Int("gzip-min-bytes", 1024, "pages no larger than this many bytes are sent uncompressed")
+var optStreamBytes = flag.Int("stream-bytes", 1<<20, "pages larger than this many bytes are streamed rather than buffered; 0 to always buffer")
+
+// supportedEncodings are the content codings write() can produce, most
+// preferred first.
+var supportedEncodings = []string{"br", "gzip"}
+
+// acceptEncoding chooses the supported coding with the highest quality in
+// an Accept-Encoding header, preferring earlier supportedEncodings when
+// qualities are equal. Codings with q=0, and malformed entries, are never
+// chosen. It returns "" when identity should be sent.
+func acceptEncoding(header string) string {
+	qualities := make(map[string]float64)
+
+	for _, entry := range strings.Split(header, ",") {
+		params := strings.Split(entry, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+
+		q, ok := 1.0, true
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if len(p) < 2 || !strings.EqualFold(p[:2], "q=") {
+				continue
+			}
+
+			var err error
+			if q, err = strconv.ParseFloat(p[2:], 64); err != nil || q < 0 || q > 1 {
+				ok = false
+			}
+		}
+
+		if ok {
+			qualities[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range supportedEncodings {
+		q, ok := qualities[coding]
+		if !ok {
+			q = qualities["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+
+	return best
+}
+
+// streamed reports whether a page of the given size is written as it is
+// rendered, without a Content-Length or precompressed copies.
+func streamed(size int) bool {
+	return *optStreamBytes > 0 && size > *optStreamBytes
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestSmallPagesSentUncompressed(t *testing.T) {
+	setFlag(t, "gzip-min-bytes", "1024")
+	testSite(t, map[string]string{
+		"small.md": "Small",
+		"large.md": strings.Repeat("Large enough to be worth compressing. ", 100),
+	})
+
+	w := get(http.HandlerFunc(renderPage), "/small", "Accept-Encoding", "gzip")
+	if ce := w.Header().Get("Content-Encoding"); ce != "" {
+		t.Errorf("small page sent with Content-Encoding %q", ce)
+	}
+	if !strings.Contains(w.Body.String(), "Small") {
+		t.Errorf("small page body %q", w.Body.String())
+	}
+
+	w = get(http.HandlerFunc(renderPage), "/large", "Accept-Encoding", "gzip")
+	if ce := w.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("large page sent with Content-Encoding %q, want gzip", ce)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "worth compressing") {
+		t.Errorf("large page decompressed to %q", b)
+	}
+}
+
+func TestContentLengthCachedPerLayout(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"layout.print.html": "<pre>{{content}}</pre>",
+		"about.md":          "About",
+	})
+
+	cf := d.Files["about"]
+	if !cf.Prerendered || cf.Layout != d.Layout || cf.Size != len(cf.Rendered) {
+		t.Fatalf("page not prerendered in its layout: %v, %v bytes", cf.Prerendered, cf.Size)
+	}
+
+	w := get(http.HandlerFunc(renderPage), "/about")
+	if !bytes.Equal(w.Body.Bytes(), cf.Rendered) {
+		t.Errorf("body %q, want the prerendered %q", w.Body.String(), cf.Rendered)
+	}
+	if cl := w.Header().Get("Content-Length"); cl != strconv.Itoa(cf.Size) {
+		t.Errorf("Content-Length %v, want %v", cl, cf.Size)
+	}
+
+	w = get(http.HandlerFunc(renderPage), "/about?view=print")
+	if body := w.Body.String(); !strings.HasPrefix(body, "<pre>") {
+		t.Errorf("print view body %q", body)
+	}
+	if cl := w.Header().Get("Content-Length"); cl != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("print view Content-Length %v, want %v", cl, w.Body.Len())
+	}
+}
+
+func TestBrotliPage(t *testing.T) {
+	d := testSite(t, map[string]string{"large.md": strings.Repeat("Large enough to be worth compressing. ", 100)})
+
+	w := get(http.HandlerFunc(renderPage), "/large", "Accept-Encoding", "br")
+	if ce := w.Header().Get("Content-Encoding"); ce != "br" {
+		t.Fatalf("Content-Encoding %q, want br", ce)
+	}
+	b, err := io.ReadAll(brotli.NewReader(w.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, d.Files["large"].Rendered) {
+		t.Errorf("decoded %q, want %q", b, d.Files["large"].Rendered)
+	}
+}
+
+func TestAcceptEncoding(t *testing.T) {
+	for _, test := range []struct{ header, want string }{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"gzip;q=0", ""},
+		{"br, gzip", "br"},
+		{"gzip, br", "br"},
+		{"gzip, br;q=0.5", "gzip"},
+		{"br;q=0, gzip;q=0.1", "gzip"},
+		{"identity;q=0", ""},
+		{"identity;q=0, gzip", "gzip"},
+		{"*", "br"},
+		{"*;q=0.2, gzip;q=0.5", "gzip"},
+		{"*, br;q=0", "gzip"},
+		{"gzip;q=abc", ""},
+		{"gzip;q=2", ""},
+		{"br;q=-1, gzip", "gzip"},
+		{", ;,", ""},
+	} {
+		if got := acceptEncoding(test.header); got != test.want {
+			t.Errorf("acceptEncoding(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestLargePagesStreamed(t *testing.T) {
+	setFlag(t, "stream-bytes", "2048")
+	text := strings.Repeat("Large enough to be streamed. ", 200)
+	d := testSite(t, map[string]string{"large.md": text})
+
+	cf := d.Files["large"]
+	if cf.Rendered != nil || cf.GzipContent != nil {
+		t.Errorf("streamed page held in memory: %v bytes rendered", len(cf.Rendered))
+	}
+
+	w := get(http.HandlerFunc(renderPage), "/large")
+	if cl := w.Header().Get("Content-Length"); cl != "" {
+		t.Errorf("streamed page sent with Content-Length %v", cl)
+	}
+	if w.Body.Len() != cf.Size || !strings.Contains(w.Body.String(), strings.TrimSpace(text)) {
+		t.Errorf("streamed body is %v bytes, want %v", w.Body.Len(), cf.Size)
+	}
+
+	w = get(http.HandlerFunc(renderPage), "/large", "Accept-Encoding", "gzip")
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != cf.Size {
+		t.Errorf("streamed gzip decompressed to %v bytes, want %v", len(b), cf.Size)
+	}
+}
+
+func TestRangeRequestsForLargePages(t *testing.T) {
+	setFlag(t, "stream-bytes", "2048")
+	testSite(t, map[string]string{
+		"large.md": strings.Repeat("Large enough to be streamed. ", 200),
+		"small.md": "Small",
+	})
+	w := get(http.HandlerFunc(renderPage), "/large")
+	full, etag := w.Body.Bytes(), w.Header().Get("Etag")
+	if ar := w.Header().Get("Accept-Ranges"); ar != "bytes" {
+		t.Errorf("Accept-Ranges %q, want bytes", ar)
+	}
+
+	w = get(http.HandlerFunc(renderPage), "/large", "Range", "bytes=10-19")
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), full[10:20]) {
+		t.Errorf("range: status %v, body %q; want 206 with %q", w.Code, w.Body.String(), full[10:20])
+	}
+	if cr := w.Header().Get("Content-Range"); cr != "bytes 10-19/"+strconv.Itoa(len(full)) {
+		t.Errorf("Content-Range %q", cr)
+	}
+
+	if w := get(http.HandlerFunc(renderPage), "/large", "Range", "bytes=10-19", "If-Range", etag); w.Code != http.StatusPartialContent {
+		t.Errorf("range with a current If-Range: status %v, want 206", w.Code)
+	}
+	if w := get(http.HandlerFunc(renderPage), "/large", "Range", "bytes=10-19", "If-Range", `"stale"`); w.Code != http.StatusOK || w.Body.Len() != len(full) {
+		t.Errorf("range with a stale If-Range: status %v, want the whole page", w.Code)
+	}
+	if w := get(http.HandlerFunc(renderPage), "/large", "Range", "bytes=10-19", "Accept-Encoding", "gzip"); w.Code != http.StatusOK || w.Header().Get("Accept-Ranges") != "" {
+		t.Errorf("range of a compressed page: status %v, Accept-Ranges %q", w.Code, w.Header().Get("Accept-Ranges"))
+	}
+	if w := get(http.HandlerFunc(renderPage), "/small", "Range", "bytes=0-1"); w.Code != http.StatusOK {
+		t.Errorf("range of a small page: status %v, want 200", w.Code)
+	}
+}
+
+func TestGzipBodyPrecomputed(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"large.md": strings.Repeat("Large enough to be worth compressing. ", 100),
+		"404.md":   strings.Repeat("Not found, but at length. ", 100),
+	})
+
+	w := get(http.HandlerFunc(renderPage), "/large", "Accept-Encoding", "gzip")
+	if !bytes.Equal(w.Body.Bytes(), d.Files["large"].GzipContent) {
+		t.Error("gzip body is not the one compressed when the tree was read")
+	}
+	w = get(http.HandlerFunc(renderPage), "/missing", "Accept-Encoding", "gzip")
+	if w.Code != 404 || !bytes.Equal(w.Body.Bytes(), d.Files["404"].GzipContent) {
+		t.Errorf("404: status %v, want the 404 page compressed when the tree was read", w.Code)
+	}
+
+	// other error pages are compressed as they are sent
+	setFlag(t, "gzip-min-bytes", "10")
+	w = get(http.HandlerFunc(renderPage), "/a/../b", "Accept-Encoding", "gzip")
+	if w.Code != 400 || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("400: status %v, Content-Encoding %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(gz); err != nil || !strings.Contains(string(b), "Bad request!") {
+		t.Errorf("400 decompressed to %q, %v", b, err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var optErrors = flag.String("errors", "", "directory of content pages named by status code, e.g. 500.md, served for errors")
+
+// readErrorPages reads the pages in the -errors directory, keyed by the
+// status code they are named after.
+func readErrorPages(base string) (map[int]*ContentFile, []error) {
+	pages := make(map[int]*ContentFile)
+	if *optErrors == "" {
+		return pages, nil
+	}
+
+	dir := filepath.Join(base, *optErrors)
+	files, err := readDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pages, nil
+		}
+		return pages, []error{fmt.Errorf("Failed to enumerate directory '%v': %v", dir, err)}
+	}
+
+	var errs []error
+	for _, file := range files {
+		n := file.Name()
+		if file.IsDir() || !isPage(n) {
+			continue
+		}
+
+		cf, err := readContentFile(dir, n)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to read error page '%v': %v", filepath.Join(dir, n), err))
+			continue
+		}
+
+		if code, err := strconv.Atoi(cf.Name); err == nil && code >= 400 && code <= 599 {
+			pages[code] = cf
+		}
+	}
+
+	return pages, errs
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteErrorEscapesMessage(t *testing.T) {
+	d := testSite(t, map[string]string{})
+
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/x", nil), d, 400, "<b>bad</b> & wrong")
+
+	if body := w.Body.String(); !strings.Contains(body, "&lt;b&gt;bad&lt;/b&gt; &amp; wrong") {
+		t.Errorf("body %q does not escape the message", body)
+	}
+}
+
+func TestWriteErrorHidesServerErrorDetail(t *testing.T) {
+	d := testSite(t, map[string]string{})
+	logs := captureLog(t)
+
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/x", nil), d, 500, "open /srv/secret: permission denied")
+
+	if w.Code != 500 {
+		t.Errorf("status %v, want 500", w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "/srv/secret") || !strings.Contains(body, "Something went wrong") {
+		t.Errorf("body %q shows the detail", body)
+	}
+	if !strings.Contains(logs.String(), "/srv/secret") {
+		t.Errorf("log %q lacks the detail", logs)
+	}
+
+	setFlag(t, "verbose-errors", "true")
+	w = httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/x", nil), d, 500, "open /srv/secret: permission denied")
+	if body := w.Body.String(); !strings.Contains(body, "/srv/secret") {
+		t.Errorf("body %q hides the detail with -verbose-errors", body)
+	}
+}
+
+func TestWriteErrorLogsWithErrorPage(t *testing.T) {
+	setFlag(t, "errors", "errors")
+	d := testSite(t, map[string]string{"errors/500.md": "Sorry"})
+	logs := captureLog(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/x", nil)
+	requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, d, 500, "database on fire")
+	})).ServeHTTP(w, r)
+
+	if body := w.Body.String(); !strings.Contains(body, "Sorry") {
+		t.Errorf("body %q is not the error page", body)
+	}
+	if id := w.Header().Get("X-Request-ID"); !strings.Contains(logs.String(), "database on fire") || !strings.Contains(logs.String(), id) {
+		t.Errorf("log %q lacks the detail or request ID %v", logs, id)
+	}
+}
+
+func TestCustomNotFoundPage(t *testing.T) {
+	testSite(t, map[string]string{"404.md": "No *such* page"})
+
+	w := get(http.HandlerFunc(renderPage), "/missing")
+	if w.Code != 404 {
+		t.Errorf("status %v, want 404", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "No <em>such</em> page") {
+		t.Errorf("body %q is not the custom 404 page", body)
+	}
+	if etag := w.Header().Get("Etag"); etag != "" {
+		t.Errorf("404 sent with Etag %v", etag)
+	}
+}
+
+func TestDefaultNotFoundPage(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About"})
+
+	w := get(http.HandlerFunc(renderPage), "/missing")
+	if w.Code != 404 {
+		t.Errorf("status %v, want 404", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "Page not found!") {
+		t.Errorf("body %q is not the default 404 page", body)
+	}
+}
+
+func TestErrorPagesDirectory(t *testing.T) {
+	setFlag(t, "errors", "errors")
+	d := testSite(t, map[string]string{
+		"errors/500.md":   "---\ntitle: Oops\n---\nSorry, *broken*",
+		"errors/400.md":   "Bad request page",
+		"errors/notes.md": "Not a status code",
+		"errors/200.md":   "Not an error",
+	})
+
+	if len(d.ErrorPages) != 2 || d.ErrorPages[500] == nil || d.ErrorPages[400] == nil {
+		t.Errorf("error pages %v, want 400 and 500", d.ErrorPages)
+	}
+
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/x", nil), d, 500, "failed")
+	if body := w.Body.String(); w.Code != 500 || !strings.Contains(body, "<title>Oops</title>") || !strings.Contains(body, "Sorry, <em>broken</em>") {
+		t.Errorf("status %v, body %q; want the 500 page in the layout", w.Code, body)
+	}
+
+	if w := get(http.HandlerFunc(renderPage), "/a/../b"); !strings.Contains(w.Body.String(), "Bad request page") {
+		t.Errorf("400 body %q, want the 400 page", w.Body.String())
+	}
+	if w := get(http.HandlerFunc(renderPage), "/errors/500"); w.Code != 404 {
+		t.Errorf("/errors/500: status %v, want 404", w.Code)
+	}
+}
+
+func TestErrorPagesDirectoryMissing(t *testing.T) {
+	setFlag(t, "errors", "errors")
+	d := testSite(t, map[string]string{"about.md": "About"})
+	if len(d.ErrorPages) != 0 {
+		t.Errorf("error pages %v without the directory", d.ErrorPages)
+	}
+
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("GET", "/x", nil), d, 500, "failed")
+	if body := w.Body.String(); w.Code != 500 || !strings.Contains(body, "Something went wrong") {
+		t.Errorf("status %v, body %q; want the default message", w.Code, body)
+	}
+}
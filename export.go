@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var optExport = flag.String("export", "", "write the site as static files to this directory and exit")
+
+// export renders every page, directory index.html and archive not beneath
+// -auth-prefix through the server's handlers and writes the results, along
+// with the not found page, the sitemap and static files, beneath out.
+func export(mux *http.ServeMux, tree *Dir, out string) error {
+	urls := []string{"/highlight.css"}
+	if *optBaseURL != "" {
+		urls = append(urls, "/sitemap.xml")
+	} else {
+		log.Println("Not exporting the sitemap, which needs -base-url for its URLs")
+	}
+
+	// a static host can't ask for the -auth-prefix credentials, so pages
+	// beneath it are left out rather than published to anyone
+	seen := make(map[string]bool)
+	skipped := 0
+	add := func(u string) {
+		if protected(u) {
+			skipped++
+			return
+		}
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+
+	tree.Walk(func(p string, dir *Dir) {
+		for _, n := range dir.FileNames() {
+			cf := dir.Files[n]
+			if cf.Draft && !*optShowDrafts {
+				continue
+			}
+
+			names := []string{n}
+			if cf.Lang != "" {
+				names = append(names, strings.TrimSuffix(n, "."+cf.Lang))
+			}
+
+			for _, name := range names {
+				u := pageURL(dir, p, name)
+				if *optCanonicalSlash == "add" && !strings.HasSuffix(u, "/") {
+					u += "/"
+				}
+				add(u)
+			}
+		}
+
+		if _, ok := indexHTML(tree, p); ok {
+			add(p)
+		}
+		for _, u := range archiveURLs(p, dir) {
+			add(u)
+		}
+	})
+
+	if skipped > 0 {
+		log.Printf("Not exporting %v pages beneath -auth-prefix %v\n", skipped, *optAuthPrefix)
+	}
+
+	for _, u := range urls {
+		if err := exportURL(mux, u, out); err != nil {
+			return err
+		}
+	}
+
+	// static hosts answer paths they have no file for with 404.html
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/404.html", nil))
+	if err := writeExport(filepath.Join(out, "404.html"), w.Body.Bytes()); err != nil {
+		return err
+	}
+	urls = append(urls, "/404.html")
+
+	log.Printf("Exported %v files to %v\n", len(urls), out)
+
+	public := filepath.Join(root, *optStaticDir)
+	if err := copyDir(public, filepath.Join(out, "public")); err != nil {
+		return err
+	}
+	assetsLock.RLock()
+	defer assetsLock.RUnlock()
+	for name, fp := range assets {
+		if err := copyFile(filepath.Join(public, filepath.FromSlash(name)), filepath.Join(out, "public", filepath.FromSlash(fp))); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range []string{"favicon.ico", "robots.txt", "manifest.json", "service-worker.js"} {
+		if err := copyFile(filepath.Join(public, n), filepath.Join(out, n)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if *optDownloadsDir != "" {
+		if err := copyDir(filepath.Join(root, *optDownloadsDir), filepath.Join(out, "downloads")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportURL writes the response to a request for the URL path u to the
+// file it would be served from by a static host.
+func exportURL(mux *http.ServeMux, u, out string) error {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", u, nil))
+
+	if w.Code != http.StatusOK {
+		return nil
+	}
+
+	name := u
+	switch {
+	case strings.HasSuffix(name, "/"):
+		name += "index.html"
+	case strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") && filepath.Ext(name) != ".html":
+		name += ".html"
+	case strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") && filepath.Ext(name) != ".txt":
+		name += ".txt"
+	}
+
+	return writeExport(filepath.Join(out, filepath.FromSlash(name)), w.Body.Bytes())
+}
+
+func writeExport(p string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(p, b, 0644); err != nil {
+		return fmt.Errorf("Could not write %v. %v", p, err)
+	}
+	return nil
+}
+
+// archiveURLs returns the URL paths of the year and month archives of the
+// dated pages in d, at p, oldest first.
+func archiveURLs(p string, d *Dir) []string {
+	months := make(map[string]bool)
+	walkArchive(p, d, func(dp string, dir *Dir) {
+		for n, cf := range dir.Files {
+			date := pageDate(cf)
+			if date.IsZero() || (dp == "/" && n == *optNotFound) || (cf.Draft && !*optShowDrafts) {
+				continue
+			}
+			if protected(linkURL(dir, dp, n)) && !protected(p) {
+				continue
+			}
+			months[fmt.Sprintf("%v%d/", p, date.Year())] = true
+			months[fmt.Sprintf("%v%d/%02d/", p, date.Year(), date.Month())] = true
+		}
+	})
+
+	urls := make([]string, 0, len(months))
+	for u := range months {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func copyDir(src, dst string) error {
+	return walkFiles(src, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == src {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(src, p)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		return copyFile(p, filepath.Join(dst, rel))
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := openFile(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportWritesEveryRoute(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"index.md":         "Home",
+		"about.md":         "About",
+		"blog/index.md":    "Blog",
+		"blog/post.md":     "---\ndate: 2023-06-01\n---\nPost",
+		"blog/older.md":    "---\ndate: 2022-12-24\n---\nOlder",
+		"files/index.html": "<p>Files</p>",
+		"404.md":           "Nothing here",
+	})
+	out := t.TempDir()
+
+	if err := export(newMux(), d, out); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"index.html":              "Home",
+		"about.html":              "About",
+		"blog/index.html":         "Blog",
+		"blog/post.html":          "Post",
+		"blog/2023/index.html":    "/blog/post",
+		"blog/2023/06/index.html": "/blog/post",
+		"blog/2022/12/index.html": "/blog/older",
+		"files/index.html":        "<p>Files</p>",
+		"404.html":                "Nothing here",
+	} {
+		b, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%v was not exported: %v", name, err)
+			continue
+		}
+		if !strings.Contains(string(b), want) {
+			t.Errorf("%v holds %q, want it to contain %q", name, b, want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "blog", "2021")); err == nil {
+		t.Error("an archive was exported for a year without pages")
+	}
+}
+
+func TestExportDefaultNotFound(t *testing.T) {
+	d := testSite(t, map[string]string{"index.md": "Home"})
+	out := t.TempDir()
+
+	if err := export(newMux(), d, out); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(out, "404.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Page not found!") {
+		t.Errorf("404.html holds %q", b)
+	}
+}
+
+func TestArchiveURLsSkipProtectedPages(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"private/plan.md": "---\ndate: 2023-05-01\n---\nPlan",
+	})
+	setFlag(t, "recursive-archives", "true")
+	setFlag(t, "auth-prefix", "/private/")
+
+	if urls := archiveURLs("/", d); len(urls) != 0 {
+		t.Errorf("archiveURLs(/) = %v, want none for a protected page", urls)
+	}
+	if urls := archiveURLs("/private/", d.Directories["private"]); len(urls) != 2 {
+		t.Errorf("archiveURLs(/private/) = %v, want the year and month", urls)
+	}
+}
+
+func TestExportSkipsProtectedPages(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"about.md":        "About",
+		"private/plan.md": "---\ndate: 2023-05-01\n---\nPlan",
+	})
+	setFlag(t, "auth-prefix", "/private/")
+	setFlag(t, "auth-user", "admin")
+	setFlag(t, "auth-pass", "secret")
+	out := t.TempDir()
+
+	if err := export(newMux(), d, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "about.html")); err != nil {
+		t.Errorf("about.html was not exported: %v", err)
+	}
+	for _, name := range []string{"private/plan.html", "private/2023/index.html"} {
+		if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(name))); err == nil {
+			t.Errorf("%v beneath -auth-prefix was exported", name)
+		}
+	}
+}
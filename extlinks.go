@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var optExternalLinksNewTab = flag.Bool("external-links-newtab", false, "open links to other sites in a new tab")
+
+var anchorPattern = regexp.MustCompile(`<a\b[^>]*>`)
+var hrefPattern = regexp.MustCompile(`\shref="([^"]*)"`)
+var relPattern = regexp.MustCompile(`\srel="([^"]*)"`)
+
+// prepareExternalLinks gives links to other sites than -base-url
+// rel="noopener noreferrer", and with -external-links-newtab a target of
+// _blank.
+func prepareExternalLinks(content []byte) []byte {
+	return anchorPattern.ReplaceAllFunc(content, func(tag []byte) []byte {
+		t := string(tag)
+
+		m := hrefPattern.FindStringSubmatch(t)
+		if m == nil || !externalURL(html.UnescapeString(m[1])) {
+			return tag
+		}
+
+		if r := relPattern.FindStringSubmatchIndex(t); r != nil {
+			rel := strings.Fields(t[r[2]:r[3]])
+			for _, v := range []string{"noopener", "noreferrer"} {
+				if !containsFold(rel, v) {
+					rel = append(rel, v)
+				}
+			}
+			t = t[:r[2]] + strings.Join(rel, " ") + t[r[3]:]
+		} else {
+			t = t[:len(t)-1] + ` rel="noopener noreferrer">`
+		}
+
+		if *optExternalLinksNewTab && !strings.Contains(t, " target=") {
+			t = t[:len(t)-1] + ` target="_blank">`
+		}
+		return []byte(t)
+	})
+}
+
+func externalURL(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+
+	base, err := url.Parse(*optBaseURL)
+	return err != nil || !strings.EqualFold(u.Hostname(), base.Hostname())
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
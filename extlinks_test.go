@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestExternalLinks(t *testing.T) {
+	setFlag(t, "base-url", "https://example.com")
+
+	for in, want := range map[string]string{
+		`<a href="https://other.com/x">x</a>`:                `<a href="https://other.com/x" rel="noopener noreferrer">x</a>`,
+		`<a href="https://other.com/x" rel="nofollow">x</a>`: `<a href="https://other.com/x" rel="nofollow noopener noreferrer">x</a>`,
+		`<a href="https://other.com/x" rel="NoOpener">x</a>`: `<a href="https://other.com/x" rel="NoOpener noreferrer">x</a>`,
+		`<a href="https://EXAMPLE.com/about">about</a>`:      `<a href="https://EXAMPLE.com/about">about</a>`,
+		`<a href="/about">about</a>`:                         `<a href="/about">about</a>`,
+		`<a href="mailto:me@other.com">mail</a>`:             `<a href="mailto:me@other.com">mail</a>`,
+		`<a name="top">top</a>`:                              `<a name="top">top</a>`,
+	} {
+		if got := string(prepareExternalLinks([]byte(in))); got != want {
+			t.Errorf("prepareExternalLinks(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	setFlag(t, "external-links-newtab", "true")
+	for in, want := range map[string]string{
+		`<a href="http://other.com/">x</a>`:                `<a href="http://other.com/" rel="noopener noreferrer" target="_blank">x</a>`,
+		`<a href="http://other.com/" target="_self">x</a>`: `<a href="http://other.com/" target="_self" rel="noopener noreferrer">x</a>`,
+		`<a href="/about">about</a>`:                       `<a href="/about">about</a>`,
+	} {
+		if got := string(prepareExternalLinks([]byte(in))); got != want {
+			t.Errorf("with -external-links-newtab prepareExternalLinks(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExternalLinksInPages(t *testing.T) {
+	d := testSite(t, map[string]string{"about.md": "[elsewhere](https://other.com/) and [home](/)"})
+
+	want := `<a href="https://other.com/" rel="noopener noreferrer">elsewhere</a> and <a href="/">home</a>`
+	if c := string(d.Files["about"].Content); c != "<p>"+want+"</p>\n" {
+		t.Errorf("content %q, want %q", c, want)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FrontMatter holds the metadata from the YAML block, delimited by "---"
+// lines, at the start of a content file.
+type FrontMatter struct {
+	Title   string   `yaml:"title"`
+	Date    Date     `yaml:"date"`
+	Summary string   `yaml:"summary"`
+	Tags    []string `yaml:"tags"`
+	Draft   bool     `yaml:"draft"`
+	Image   string   `yaml:"image"`
+
+	// CacheControl replaces the -cache-control header for the page.
+	CacheControl string `yaml:"cache-control"`
+}
+
+// Date is a front matter date, which may be written as 2006-01-02,
+// 2006-01-02 15:04 or in RFC 3339 format. Dates without a time zone are
+// in UTC.
+type Date struct {
+	time.Time
+}
+
+var dateFormats = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+func parseDate(s string) (Date, error) {
+	for _, f := range dateFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return Date{t}, nil
+		}
+	}
+	return Date{}, fmt.Errorf("invalid date '%v'; expected 2006-01-02, 2006-01-02 15:04 or RFC 3339", s)
+}
+
+func (d *Date) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	var err error
+	*d, err = parseDate(s)
+	return err
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	var err error
+	*d, err = parseDate(s)
+	return err
+}
+
+// splitFrontMatter separates a leading front matter block from the rest
+// of b. If b does not start with a complete block, the returned front
+// matter is nil and the body is b unchanged.
+func splitFrontMatter(b []byte) ([]byte, []byte) {
+	line, rest := nextLine(b)
+	if string(bytes.TrimRight(line, "\r")) != "---" {
+		return nil, b
+	}
+
+	fm := rest
+	for len(rest) > 0 {
+		start := len(fm) - len(rest)
+		line, rest = nextLine(rest)
+
+		if string(bytes.TrimRight(line, "\r")) == "---" {
+			return fm[:start], rest
+		}
+	}
+
+	return nil, b
+}
+
+func nextLine(b []byte) ([]byte, []byte) {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i], b[i+1:]
+	}
+	return b, nil
+}
+
+func parseFrontMatter(b []byte) (FrontMatter, []byte, error) {
+	var fm FrontMatter
+
+	raw, body := splitFrontMatter(b)
+	if raw == nil {
+		return fm, body, nil
+	}
+
+	if err := yaml.Unmarshal(raw, &fm); err != nil {
+		return fm, nil, fileLines(err)
+	}
+
+	return fm, body, nil
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+):`)
+var unknownFieldPattern = regexp.MustCompile(`^(line \d+:) field (\S+) not found in type`)
+
+// fileLines makes the line numbers in a YAML error count from the start of
+// the file, rather than of the front matter following its "---" line.
+func fileLines(err error) error {
+	return errors.New(yamlLinePattern.ReplaceAllStringFunc(err.Error(), func(m string) string {
+		n, _ := strconv.Atoi(yamlLinePattern.FindStringSubmatch(m)[1])
+		return "line " + strconv.Itoa(n+1) + ":"
+	}))
+}
+
+// frontMatterWarnings lists the fields in the front matter of b which are
+// not known, such as misspelt ones, which are otherwise ignored.
+func frontMatterWarnings(b []byte) []string {
+	raw, _ := splitFrontMatter(b)
+	if raw == nil {
+		return nil
+	}
+
+	var fm FrontMatter
+	err := yaml.UnmarshalStrict(raw, &fm)
+	if err == nil {
+		return nil
+	}
+
+	var warnings []string
+	if te, ok := err.(*yaml.TypeError); ok {
+		for _, e := range te.Errors {
+			if m := unknownFieldPattern.FindStringSubmatch(e); m != nil {
+				warnings = append(warnings, fmt.Sprintf("%v unknown field '%v'", fileLines(errors.New(m[1])), m[2]))
+			}
+		}
+	}
+	return warnings
+}
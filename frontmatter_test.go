@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrontMatterParsed(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"post.md": "---\ntitle: A Post\ndate: 2023-06-01\nsummary: About things\ntags: [go, web]\nimage: /public/post.png\ncache-control: no-cache\n---\nThe *body*\n",
+	})
+
+	cf := d.Files["post"]
+	want := FrontMatter{
+		Title:        "A Post",
+		Date:         Date{time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+		Summary:      "About things",
+		Tags:         []string{"go", "web"},
+		Image:        "/public/post.png",
+		CacheControl: "no-cache",
+	}
+	if !reflect.DeepEqual(cf.FrontMatter, want) {
+		t.Errorf("front matter %+v, want %+v", cf.FrontMatter, want)
+	}
+
+	content := string(cf.Content)
+	if strings.Contains(content, "---") || strings.Contains(content, "title:") || !strings.Contains(content, "The <em>body</em>") {
+		t.Errorf("content %q still holds the front matter block", content)
+	}
+}
+
+func TestWithoutFrontMatter(t *testing.T) {
+	for _, in := range []string{"No front matter", "---\nnot: closed\n", "Text\n---\ntitle: later\n---\n"} {
+		if fm, body := splitFrontMatter([]byte(in)); fm != nil || string(body) != in {
+			t.Errorf("splitFrontMatter(%q) = %q, %q; want the input unchanged", in, fm, body)
+		}
+	}
+}
+
+func TestFrontMatterWarnings(t *testing.T) {
+	logged := captureLog(t)
+	testSite(t, map[string]string{"post.md": "---\ntitle: A Post\ntittle: Typo\nsumary: Typo\n---\nPost\n"})
+
+	for _, want := range []string{"post.md' line 3: unknown field 'tittle'", "post.md' line 4: unknown field 'sumary'"} {
+		if !strings.Contains(logged.String(), want) {
+			t.Errorf("log %q does not warn %q", logged, want)
+		}
+	}
+
+	if w := frontMatterWarnings([]byte("---\ntitle: Fine\n---\n")); w != nil {
+		t.Errorf("warnings %q for known fields", w)
+	}
+}
+
+func TestFrontMatterErrorLines(t *testing.T) {
+	for in, want := range map[string]string{
+		"---\ntitle: A Post\ndate: yesterday\n---\n": "invalid date 'yesterday'",
+		"---\ntitle: A Post\ntags: [a\n---\n":        "line 3:",
+	} {
+		if _, _, err := parseFrontMatter([]byte(in)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parsing %q: error %v, want one saying %q", in, err, want)
+		}
+	}
+}
+
+func TestDateFormats(t *testing.T) {
+	auckland := time.FixedZone("", 13*60*60)
+	for in, want := range map[string]time.Time{
+		"2023-06-01":                time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+		"2023-06-01 14:30":          time.Date(2023, 6, 1, 14, 30, 0, 0, time.UTC),
+		"2023-06-01 14:30:15":       time.Date(2023, 6, 1, 14, 30, 15, 0, time.UTC),
+		"2023-06-01T14:30:15":       time.Date(2023, 6, 1, 14, 30, 15, 0, time.UTC),
+		"2023-06-01T14:30:15+13:00": time.Date(2023, 6, 1, 14, 30, 15, 0, auckland),
+	} {
+		d, err := parseDate(in)
+		if err != nil {
+			t.Errorf("parseDate(%q): %v", in, err)
+		} else if !d.Equal(want) {
+			t.Errorf("parseDate(%q) = %v, want %v", in, d, want)
+		}
+	}
+
+	for _, in := range []string{"1 June 2023", "2023/06/01", "2023-13-01", ""} {
+		if _, err := parseDate(in); err == nil {
+			t.Errorf("parseDate(%q) succeeded", in)
+		}
+	}
+
+	var d Date
+	if err := d.UnmarshalJSON([]byte(`"2023-06-01 14:30"`)); err != nil || d.Hour() != 14 {
+		t.Errorf("UnmarshalJSON = %v, %v", d, err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var optEmbedded = flag.Bool("embedded", false, "serve the site embedded in the binary when built with -tags embed, rather than -path")
+
+// fsys holds the site's files, found at fsysRoot. On disk it is the whole
+// file system, so the -public and other directories may lie outside the
+// root; embedded, it is the embedded site with the root at its top. Any
+// other fs.FS, such as an fstest.MapFS, can be used in the same way. The
+// paths used throughout are OS paths as if on disk, which sitePath maps
+// into fsys.
+var fsys fs.FS = os.DirFS("/")
+var fsysRoot = "/"
+
+// embedded is set by embed.go when built with the embed tag.
+var embedded fs.FS
+
+func setupFS() error {
+	switch {
+	case *optEmbedded:
+		if embedded == nil {
+			return errors.New("-embedded requires a binary built with -tags embed")
+		}
+		fsys, fsysRoot = embedded, root
+	case *optBackend == "s3":
+		s, err := newS3FS()
+		if err != nil {
+			return err
+		}
+		fsys, fsysRoot = s, root
+	case *optBackend != "disk":
+		return fmt.Errorf("Unknown backend '%v'", *optBackend)
+	}
+	return nil
+}
+
+func sitePath(name string) string {
+	rel, err := filepath.Rel(fsysRoot, name)
+	if err != nil {
+		return name
+	}
+	return filepath.ToSlash(rel)
+}
+
+func readFile(name string) ([]byte, error) {
+	return fs.ReadFile(fsys, sitePath(name))
+}
+
+func openFile(name string) (fs.File, error) {
+	return fsys.Open(sitePath(name))
+}
+
+func statFile(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys, sitePath(name))
+}
+
+// readDir lists the directory sorted by name, as ioutil.ReadDir does.
+func readDir(name string) ([]fs.FileInfo, error) {
+	entries, err := fs.ReadDir(fsys, sitePath(name))
+
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return infos, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
+
+// walkFiles walks the tree at name like filepath.Walk.
+func walkFiles(name string, fn filepath.WalkFunc) error {
+	return fs.WalkDir(fsys, sitePath(name), func(p string, d fs.DirEntry, err error) error {
+		p = filepath.Join(fsysRoot, filepath.FromSlash(p))
+		if err != nil {
+			return fn(p, nil, err)
+		}
+
+		info, err := d.Info()
+		return fn(p, info, err)
+	})
+}
+
+// subFS is the part of fsys beneath the directory name.
+func subFS(name string) fs.FS {
+	sub, err := fs.Sub(fsys, sitePath(name))
+	if err != nil {
+		return emptyFS{}
+	}
+	return sub
+}
+
+type emptyFS struct{}
+
+func (emptyFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// useFS serves the site from f, with the root at its top, for the rest of
+// the test.
+func useFS(t *testing.T, f fs.FS) {
+	previousFS, previousRoot, previous := fsys, fsysRoot, root
+	t.Cleanup(func() { fsys, fsysRoot, root = previousFS, previousRoot, previous })
+
+	root = "/site"
+	fsys, fsysRoot = f, root
+}
+
+func TestSiteFromFS(t *testing.T) {
+	useFS(t, fstest.MapFS{
+		"layout.html":       {Data: []byte(testLayout)},
+		"about.md":          {Data: []byte("---\ntitle: About\n---\nAbout *us*")},
+		"blog/post.md":      {Data: []byte("Post")},
+		".public/style.css": {Data: []byte("body {}")},
+	})
+	loadSite(t)
+	h := testHandler()
+
+	if body := get(h, "/about").Body.String(); body != "<html><head><title>About</title></head><body><p>About <em>us</em></p>\n</body></html>" {
+		t.Errorf("/about body %q", body)
+	}
+	if w := get(h, "/blog/post"); w.Code != 200 {
+		t.Errorf("/blog/post: status %v, want 200", w.Code)
+	}
+	if w := get(h, "/public/style.css"); w.Code != 200 || w.Body.String() != "body {}" {
+		t.Errorf("/public/style.css: status %v, body %q", w.Code, w.Body.String())
+	}
+}
+
+func TestEmbeddedRequiresTag(t *testing.T) {
+	if embedded != nil {
+		t.Skip("built with -tags embed")
+	}
+	setFlag(t, "embedded", "true")
+	if err := setupFS(); err == nil || !strings.Contains(err.Error(), "-tags embed") {
+		t.Errorf("setupFS error %v", err)
+	}
+}
+
+func TestDefaultFSIsDisk(t *testing.T) {
+	writeSite(t, map[string]string{"about.md": "About", "blog/post.md": "Post"})
+
+	if b, err := readFile(filepath.Join(root, "about.md")); err != nil || string(b) != "About" {
+		t.Errorf("readFile = %q, %v", b, err)
+	}
+	infos, err := readDir(root)
+	if err != nil || len(infos) != 2 || infos[0].Name() != "about.md" || infos[1].Name() != "blog" {
+		t.Errorf("readDir = %v, %v", infos, err)
+	}
+	if sitePath(filepath.Join(root, "blog")) != strings.TrimPrefix(filepath.ToSlash(root), "/")+"/blog" {
+		t.Errorf("sitePath = %q", sitePath(filepath.Join(root, "blog")))
+	}
+}
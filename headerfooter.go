@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"path"
+	"path/filepath"
+)
+
+var optHeaderMD = flag.String("header-md", "", "markdown file, relative to the root, rendered before the content of every page in a layout")
+var optFooterMD = flag.String("footer-md", "", "markdown file, relative to the root, rendered after the content of every page in a layout")
+
+// readFragment renders the markdown file at name, relative to the root,
+// or returns nil if name is empty.
+func readFragment(name string) ([]byte, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	b, err := readFile(filepath.Join(root, name))
+	if err != nil {
+		return nil, err
+	}
+
+	_, body, err := parseFrontMatter(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if body, err = expandIncludes(body, []string{path.Clean("/" + filepath.ToSlash(name))}); err != nil {
+		return nil, err
+	}
+
+	return newRenderer().Render(body)
+}
+
+// setHeaderFooter gives every layout in the tree the header and footer,
+// which are part of their hash so that ETags change along with them.
+func setHeaderFooter(d *Dir, header, footer []byte) {
+	if header == nil && footer == nil {
+		return
+	}
+
+	seen := make(map[*LayoutFile]bool)
+	set := func(lf *LayoutFile) {
+		if lf == nil || seen[lf] {
+			return
+		}
+		seen[lf] = true
+
+		lf.Header, lf.Footer = header, footer
+		lf.Hash = hash(append(append(append([]byte(nil), lf.Hash...), header...), footer...))
+	}
+
+	d.Walk(func(_ string, dir *Dir) {
+		set(dir.Layout)
+		for _, lf := range dir.Views {
+			set(lf)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHeaderAndFooter(t *testing.T) {
+	setFlag(t, "ext", "md,txt")
+	testSite(t, map[string]string{
+		"layout.html":        "<main>{{content}}</main>",
+		"layout.print.html":  "<pre>{{content}}</pre>",
+		"partials/header.md": "*Site* header",
+		"partials/footer.md": "---\ntitle: ignored\n---\nFooter {{include partials/year.md}}",
+		"partials/year.md":   "2023",
+		"about.md":           "About",
+		"notes.txt":          "Notes",
+	})
+	etag := get(http.HandlerFunc(renderPage), "/about").Header().Get("Etag")
+
+	setFlag(t, "header-md", "partials/header.md")
+	setFlag(t, "footer-md", "partials/footer.md")
+	loadSite(t)
+	h := http.HandlerFunc(renderPage)
+
+	w := get(h, "/about")
+	if body := w.Body.String(); body != "<main><p><em>Site</em> header</p>\n<p>About</p>\n<p>Footer 2023</p>\n</main>" {
+		t.Errorf("body %q, want the header and footer around the content", body)
+	}
+	if w.Header().Get("Etag") == etag {
+		t.Error("the page's Etag did not change with the footer")
+	}
+	if body := get(h, "/about?view=print").Body.String(); body != "<pre><p><em>Site</em> header</p>\n<p>About</p>\n<p>Footer 2023</p>\n</pre>" {
+		t.Errorf("print view %q", body)
+	}
+	if body := get(h, "/notes").Body.String(); body != "Notes" {
+		t.Errorf("text page %q, want it without the header and footer", body)
+	}
+}
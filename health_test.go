@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReadyOnceLoaded(t *testing.T) {
+	writeSite(t, map[string]string{"layout.html": testLayout, "about.md": "About"})
+	setTree(nil)
+	h := testHandler()
+
+	for u, want := range map[string]int{"/healthz": 200, "/readyz": 503, "/about": 503} {
+		if w := get(h, u); w.Code != want {
+			t.Errorf("%v before loading: status %v, want %v", u, w.Code, want)
+		}
+	}
+
+	loadSite(t)
+	for _, u := range []string{"/healthz", "/readyz", "/about"} {
+		if w := get(h, u); w.Code != http.StatusOK {
+			t.Errorf("%v once loaded: status %v, want 200", u, w.Code)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+var optHighlightStyle = flag.String("highlight-style", "github", "color scheme of the code highlighting served at /highlight.css")
+
+func setupHighlight(mux *http.ServeMux) {
+	style, ok := styles.Registry[*optHighlightStyle]
+	if !ok {
+		log.Fatalf("Unknown highlight style '%v'", *optHighlightStyle)
+	}
+
+	b := &bytes.Buffer{}
+	if err := highlighter.WriteCSS(b, style); err != nil {
+		log.Fatalf("Could not generate the highlight CSS. %v", err)
+	}
+
+	css := b.Bytes()
+	modTime := time.Now()
+
+	mux.Handle("/highlight.css", staticCacheControl(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=UTF-8")
+		http.ServeContent(w, r, "highlight.css", modTime, bytes.NewReader(css))
+	})))
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoFenceHighlighted(t *testing.T) {
+	d := testSite(t, map[string]string{"code.md": "```go\nfunc main() {}\n```\n"})
+
+	content := string(d.Files["code"].Content)
+	if !strings.Contains(content, `<span class="kd">func</span>`) || !strings.Contains(content, `<span class="nf">main</span>`) {
+		t.Errorf("content %q is not highlighted", content)
+	}
+}
+
+func TestHighlightCSSServed(t *testing.T) {
+	testSite(t, map[string]string{})
+
+	w := get(testHandler(), "/highlight.css")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "text/css; charset=UTF-8" || !strings.Contains(w.Body.String(), ".kd") {
+		t.Errorf("status %v, Content-Type %q, body %.80q", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
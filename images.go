@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var optImgDimensions = flag.Bool("img-dimensions", false, "add width and height to images served from the public directory")
+
+var imgPattern = regexp.MustCompile(`<img\b[^>]*>`)
+var imgSrcPattern = regexp.MustCompile(`\ssrc="([^"]*)"`)
+
+// prepareImages makes the images in content load lazily and, with
+// -img-dimensions, gives those under /public/ their width and height so
+// the page does not move as they load.
+func prepareImages(content []byte) []byte {
+	return imgPattern.ReplaceAllFunc(content, func(tag []byte) []byte {
+		t := string(tag)
+		attrs := ""
+
+		if !strings.Contains(t, " loading=") {
+			attrs += ` loading="lazy"`
+		}
+
+		if *optImgDimensions && !strings.Contains(t, " width=") && !strings.Contains(t, " height=") {
+			if m := imgSrcPattern.FindStringSubmatch(t); m != nil {
+				if w, h, ok := imageSize(m[1]); ok {
+					attrs += fmt.Sprintf(` width="%d" height="%d"`, w, h)
+				}
+			}
+		}
+
+		body := strings.TrimRight(strings.TrimSuffix(strings.TrimSuffix(t, ">"), "/"), " ")
+		return []byte(body + attrs + t[len(body):])
+	})
+}
+
+// imageSize reads the dimensions of a local image in the public directory.
+func imageSize(src string) (int, int, bool) {
+	u, err := url.Parse(src)
+	if err != nil || u.IsAbs() || u.Host != "" || !strings.HasPrefix(u.Path, "/public/") {
+		return 0, 0, false
+	}
+
+	p := path.Clean("/" + strings.TrimPrefix(u.Path, "/public/"))
+	f, err := openFile(filepath.Join(root, *optStaticDir, filepath.FromSlash(p)))
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	c, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, false
+	}
+	return c.Width, c.Height, true
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestImagesLoadLazily(t *testing.T) {
+	for in, want := range map[string]string{
+		`<img src="/a.png" alt="a">`:         `<img src="/a.png" alt="a" loading="lazy">`,
+		`<img src="/a.png" alt="a" />`:       `<img src="/a.png" alt="a" loading="lazy" />`,
+		`<img src="/a.png" loading="eager">`: `<img src="/a.png" loading="eager">`,
+	} {
+		if got := string(prepareImages([]byte(in))); got != want {
+			t.Errorf("prepareImages(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestImageDimensions(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := png.Encode(b, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	writeSite(t, map[string]string{".public/photo.png": b.String()})
+
+	const page = `<img src="/public/photo.png"><img src="https://example.com/photo.png"><img src="/public/missing.png">`
+	if got := string(prepareImages([]byte(page))); strings.Contains(got, "width=") {
+		t.Errorf("prepareImages without -img-dimensions = %q", got)
+	}
+
+	setFlag(t, "img-dimensions", "true")
+	got := string(prepareImages([]byte(page)))
+	if !strings.Contains(got, `<img src="/public/photo.png" loading="lazy" width="40" height="30">`) {
+		t.Errorf("prepareImages = %q, want the local image's dimensions", got)
+	}
+	if strings.Count(got, "width=") != 1 {
+		t.Errorf("prepareImages = %q, gave dimensions to remote or missing images", got)
+	}
+}
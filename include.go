@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var optPartials = flag.String("partials", "partials", "directory of markdown fragments for {{include}}, which are not served as pages")
+
+const maxIncludeDepth = 8
+
+var includePattern = regexp.MustCompile(`\{\{\s*include\s+([^}\s]+)\s*\}\}`)
+
+// expandIncludes replaces each {{include path}} in the markdown b with the
+// content of the file at path, relative to the root, after expanding its
+// own includes. stack holds the files being expanded, to detect cycles.
+func expandIncludes(b []byte, stack []string) ([]byte, error) {
+	var err error
+
+	out := includePattern.ReplaceAllFunc(b, func(directive []byte) []byte {
+		if err != nil {
+			return nil
+		}
+
+		p := path.Clean("/" + string(includePattern.FindSubmatch(directive)[1]))
+
+		for _, s := range stack {
+			if s == p {
+				err = fmt.Errorf("include cycle %v -> %v", strings.Join(stack, " -> "), p)
+				return nil
+			}
+		}
+
+		if len(stack) >= maxIncludeDepth {
+			err = fmt.Errorf("includes nested more than %v deep at %v", maxIncludeDepth, p)
+			return nil
+		}
+
+		var inc []byte
+		if inc, err = readFile(filepath.Join(root, filepath.FromSlash(p))); err != nil {
+			return nil
+		}
+
+		if _, body, fmErr := parseFrontMatter(inc); fmErr == nil {
+			inc = body
+		}
+
+		inc, err = expandIncludes(inc, append(stack[:len(stack):len(stack)], p))
+		return inc
+	})
+
+	return out, err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIncludes(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"about.md":         "About\n\n{{include partials/bio.md}}\n",
+		"partials/bio.md":  "---\ntitle: Bio\n---\nWritten by {{ include partials/name.md }}.\n",
+		"partials/name.md": "Jo",
+	})
+
+	if c := string(d.Files["about"].Content); !strings.Contains(c, "Written by Jo.") || strings.Contains(c, "title:") {
+		t.Errorf("content %q, want the nested includes without front matter", c)
+	}
+	if w := get(http.HandlerFunc(renderPage), "/partials/bio"); w.Code != http.StatusNotFound {
+		t.Errorf("/partials/bio: status %v, want 404", w.Code)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	writeSite(t, map[string]string{
+		"partials/a.md": "{{include partials/b.md}}",
+		"partials/b.md": "{{include /partials/a.md}}",
+	})
+
+	_, err := expandIncludes([]byte("{{include partials/a.md}}"), []string{"/page.md"})
+	if err == nil || !strings.Contains(err.Error(), "include cycle /page.md -> /partials/a.md -> /partials/b.md -> /partials/a.md") {
+		t.Errorf("expanding a cycle: error %v", err)
+	}
+
+	if _, err := expandIncludes([]byte("{{include partials/missing.md}}"), []string{"/page.md"}); err == nil {
+		t.Error("including a missing file succeeded")
+	}
+}
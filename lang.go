@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var optDefaultLang = flag.String("default-lang", "", "language of pages served when no variant matches the request's languages")
+
+var langPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// pageLang returns the language tag between the base name and extension
+// of a content file, as in "about.fr.md", or "" if there is none.
+func pageLang(name string) string {
+	if ext := filepath.Ext(name); len(ext) > 1 && langPattern.MatchString(ext[1:]) {
+		return ext[1:]
+	}
+	return ""
+}
+
+// requestLanguages returns the languages the client asked for, best first:
+// a ?lang= override, then the Accept-Language header by quality. Regional
+// tags are followed by their primary language.
+func requestLanguages(r *http.Request) []string {
+	type entry struct {
+		tag string
+		q   float64
+	}
+
+	var entries []entry
+	for _, e := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		params := strings.Split(e, ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if len(p) > 2 && strings.EqualFold(p[:2], "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > 0 {
+			entries = append(entries, entry{tag, q})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].q > entries[j].q })
+
+	var langs []string
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		langs = append(langs, strings.ToLower(lang))
+	}
+	for _, e := range entries {
+		langs = append(langs, e.tag)
+	}
+
+	var all []string
+	for _, lang := range langs {
+		all = append(all, lang)
+		if i := strings.Index(lang, "-"); i > 0 {
+			all = append(all, lang[:i])
+		}
+	}
+
+	return all
+}
+
+// findVariant returns the page named name in d in the first of langs with
+// a variant, otherwise the page without a language, otherwise the page in
+// -default-lang.
+func (d *Dir) findVariant(name string, langs []string, drafts bool) *ContentFile {
+	candidates := make([]string, 0, len(langs)+2)
+	for _, lang := range langs {
+		candidates = append(candidates, name+"."+lang)
+	}
+	candidates = append(candidates, name)
+	if *optDefaultLang != "" {
+		candidates = append(candidates, name+"."+*optDefaultLang)
+	}
+
+	for _, n := range candidates {
+		if cf, ok := d.Files[n]; ok && (!cf.Draft || drafts) {
+			return cf
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPageLang(t *testing.T) {
+	for name, want := range map[string]string{"about.fr": "fr", "about.pt-br": "pt-br", "about": "", "v1.2": "", "about.FR": ""} {
+		if got := pageLang(name); got != want {
+			t.Errorf("pageLang(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRequestLanguages(t *testing.T) {
+	r := httptest.NewRequest("GET", "/about?lang=DE", nil)
+	r.Header.Set("Accept-Language", "en;q=0.5, fr-CA, *;q=0.1, es;q=0")
+
+	want := []string{"de", "fr-ca", "fr", "en"}
+	if got := requestLanguages(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("requestLanguages = %q, want %q", got, want)
+	}
+}
+
+func TestLanguageVariants(t *testing.T) {
+	testSite(t, map[string]string{
+		"about.md":      "About",
+		"about.fr.md":   "À propos",
+		"contact.de.md": "Kontakt",
+	})
+
+	for _, test := range []struct {
+		u, acceptLanguage, want, lang string
+	}{
+		{"/about", "fr-CA, en;q=0.8", "À propos", "fr"},
+		{"/about", "de", "About", ""},
+		{"/about", "", "About", ""},
+		{"/about?lang=fr", "en", "À propos", "fr"},
+	} {
+		w := get(http.HandlerFunc(renderPage), test.u, "Accept-Language", test.acceptLanguage)
+		if !strings.Contains(w.Body.String(), test.want) || w.Header().Get("Content-Language") != test.lang {
+			t.Errorf("%v in %q: Content-Language %q, body %q; want %q", test.u, test.acceptLanguage, w.Header().Get("Content-Language"), w.Body.String(), test.want)
+		}
+	}
+
+	if w := get(http.HandlerFunc(renderPage), "/contact", "Accept-Language", "en"); w.Code != http.StatusNotFound {
+		t.Errorf("/contact with only a German variant: status %v, want 404", w.Code)
+	}
+	setFlag(t, "default-lang", "de")
+	if w := get(http.HandlerFunc(renderPage), "/contact", "Accept-Language", "en"); !strings.Contains(w.Body.String(), "Kontakt") {
+		t.Errorf("/contact with -default-lang=de: body %q", w.Body.String())
+	}
+}
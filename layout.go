@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LayoutPart is either static text from a layout or, when Token is set,
+// a placeholder such as {{content}} that is filled in for each page.
+type LayoutPart struct {
+	Text  []byte
+	Token string
+}
+
+var layoutTokens = map[string]func(cf *ContentFile) []byte{
+	"content":     func(cf *ContentFile) []byte { return cf.Content },
+	"title":       func(cf *ContentFile) []byte { return []byte(html.EscapeString(cf.Title)) },
+	"toc":         func(cf *ContentFile) []byte { return cf.TOCHTML },
+	"related":     func(cf *ContentFile) []byte { return cf.RelatedHTML },
+	"meta":        func(cf *ContentFile) []byte { return cf.MetaHTML },
+	"canonical":   func(cf *ContentFile) []byte { return cf.CanonicalHTML },
+	"readingtime": func(cf *ContentFile) []byte { return []byte(strconv.Itoa(cf.ReadingTime) + " min read") },
+}
+
+// parseLayout splits a layout on its {{placeholders}}, replacing each
+// {{asset "path"}} with the fingerprinted URL of the file, each
+// {{site.name}} with the site variable and {{year}} with the year the
+// layout is loaded in, so that they are part of its hash. Unknown
+// placeholders are an error, as is a layout without exactly one
+// {{content}}.
+func parseLayout(b []byte) ([]LayoutPart, error) {
+	parts := make([]LayoutPart, 0)
+	content := 0
+
+	for len(b) > 0 {
+		i := bytes.Index(b, []byte("{{"))
+		if i < 0 {
+			parts = append(parts, LayoutPart{Text: b})
+			break
+		}
+
+		j := bytes.Index(b[i:], []byte("}}"))
+		if j < 0 {
+			return nil, fmt.Errorf("unterminated placeholder at '%.20s'", b[i:])
+		}
+
+		if i > 0 {
+			parts = append(parts, LayoutPart{Text: b[:i]})
+		}
+
+		token := strings.TrimSpace(string(b[i+2 : i+j]))
+
+		if name, ok := assetToken(token); ok {
+			u, err := assetURL(name)
+			if err != nil {
+				return nil, err
+			}
+
+			parts = append(parts, LayoutPart{Text: []byte(u)})
+			b = b[i+j+2:]
+			continue
+		}
+
+		if v, ok, err := siteValue(token); ok {
+			if err != nil {
+				return nil, err
+			}
+
+			parts = append(parts, LayoutPart{Text: []byte(v)})
+			b = b[i+j+2:]
+			continue
+		}
+
+		if token == "year" {
+			parts = append(parts, LayoutPart{Text: []byte(strconv.Itoa(time.Now().Year()))})
+			b = b[i+j+2:]
+			continue
+		}
+
+		if _, ok := layoutTokens[token]; !ok {
+			return nil, fmt.Errorf("unknown placeholder {{%v}}", token)
+		}
+		if token == "content" {
+			content++
+		}
+
+		parts = append(parts, LayoutPart{Token: token})
+		b = b[i+j+2:]
+	}
+
+	if content != 1 {
+		return nil, fmt.Errorf("{{content}} token must appear exactly once, found %v", content)
+	}
+
+	return parts, nil
+}
+
+// layoutHash identifies the layout's parts, which include the fingerprints
+// of its assets.
+func layoutHash(parts []LayoutPart) []byte {
+	b := &bytes.Buffer{}
+	for _, p := range parts {
+		b.Write(p.Text)
+		if p.Token != "" {
+			b.WriteString("{{" + p.Token + "}}")
+		}
+	}
+	return hash(b.Bytes())
+}
+
+// Render writes the page within the layout.
+func (lf *LayoutFile) Render(w io.Writer, cf *ContentFile) {
+	for _, p := range lf.Parts {
+		switch p.Token {
+		case "":
+			w.Write(p.Text)
+		case "content":
+			w.Write(lf.Header)
+			w.Write(cf.Content)
+			w.Write(lf.Footer)
+		default:
+			w.Write(layoutTokens[p.Token](cf))
+		}
+	}
+}
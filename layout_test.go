@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutRepeatedPlaceholders(t *testing.T) {
+	parts, err := parseLayout([]byte("<title>{{title}}</title>{{ year }}<h1>{{title}}</h1>{{content}}&copy; {{year}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cf := &ContentFile{Content: []byte("<p>Body</p>")}
+	cf.Title = "Fish & Chips"
+
+	b := &bytes.Buffer{}
+	(&LayoutFile{Parts: parts}).Render(b, cf)
+
+	year := strconv.Itoa(time.Now().Year())
+	want := "<title>Fish &amp; Chips</title>" + year + "<h1>Fish &amp; Chips</h1><p>Body</p>&copy; " + year
+	if b.String() != want {
+		t.Errorf("rendered %q, want %q", b, want)
+	}
+}
+
+func TestLayoutYearIsPartOfItsHash(t *testing.T) {
+	parts, err := parseLayout([]byte("{{content}}&copy; {{year}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range parts {
+		if p.Token == "year" {
+			t.Fatal("{{year}} is filled in per page, not when the layout is loaded")
+		}
+	}
+
+	literal, err := parseLayout([]byte("{{content}}&copy; " + strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(layoutHash(parts), layoutHash(literal)) {
+		t.Error("the layout's hash does not include the year")
+	}
+}
+
+func TestLayoutPlaceholderErrors(t *testing.T) {
+	for layout, want := range map[string]string{
+		"{{content}}{{nonsense}}": "unknown placeholder",
+		"<p>no content</p>":       "exactly once",
+		"{{content}}{{content}}":  "exactly once",
+		"{{content}}{{title":      "unterminated",
+	} {
+		if _, err := parseLayout([]byte(layout)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parsing %q: error %v, want one saying %q", layout, err, want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var optCheckLinks = flag.Bool("check-links", false, "report broken links in the content pages and exit instead of serving")
+var optCheckExternal = flag.Bool("check-external", false, "also check http and https links to other sites with -check-links")
+
+// checkLinks reports every link in the tree's pages that does not resolve,
+// returning the number of broken links found.
+func checkLinks(mux *http.ServeMux, tree *Dir) int {
+	base, _ := url.Parse(*optBaseURL)
+	client := &http.Client{Timeout: 10 * time.Second}
+	external := make(map[string]bool)
+
+	checked, broken := 0, 0
+
+	tree.Walk(func(p string, dir *Dir) {
+		for _, n := range dir.FileNames() {
+			// relative links resolve against the URL the page is linked at
+			page := linkURL(dir, p, n)
+
+			for _, href := range pageLinks(dir.Files[n].Content) {
+				u, err := url.Parse(href)
+				if err == nil {
+					u = (&url.URL{Path: page}).ResolveReference(u)
+				}
+
+				var ok bool
+				switch {
+				case err != nil:
+					ok = false
+				case (u.Scheme == "" && u.Host == "") || (base != nil && base.Host != "" && strings.EqualFold(u.Host, base.Host)):
+					if u.Path == page && u.Fragment != "" {
+						continue
+					}
+					ok = internalLinkExists(mux, tree, u.Path)
+				case u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "":
+					if !*optCheckExternal {
+						continue
+					}
+					// a protocol-relative link to another site
+					if u.Scheme == "" {
+						u.Scheme = "https"
+					}
+
+					var seen bool
+					if ok, seen = external[u.String()]; !seen {
+						ok = externalLinkExists(client, u.String())
+						external[u.String()] = ok
+					}
+				default:
+					continue
+				}
+
+				checked++
+				if !ok {
+					broken++
+					log.Printf("Broken link in %v: %v\n", page, href)
+				}
+			}
+		}
+	})
+
+	log.Printf("Checked %v links, found %v broken\n", checked, broken)
+	return broken
+}
+
+func pageLinks(content []byte) []string {
+	links := make([]string, 0)
+	z := html.NewTokenizer(bytes.NewReader(content))
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, more := z.TagName()
+			if string(name) != "a" {
+				continue
+			}
+
+			for more {
+				var k, v []byte
+				k, v, more = z.TagAttr()
+				if string(k) == "href" {
+					links = append(links, string(v))
+				}
+			}
+		}
+	}
+}
+
+// internalLinkExists reports whether the server would answer the URL path
+// p with something other than a 404, looking up pages in the order
+// renderPage does.
+func internalLinkExists(mux *http.ServeMux, tree *Dir, p string) bool {
+	clean := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+
+	_, pattern := mux.Handler(&http.Request{Method: "GET", URL: &url.URL{Path: clean}})
+	public := filepath.Join(root, *optStaticDir)
+
+	switch pattern {
+	case "/":
+		if _, _, ok := tree.Redirects.Find(clean); ok {
+			return true
+		}
+		if to, ok := canonicalPath(tree, clean); ok {
+			return internalLinkExists(mux, tree, to)
+		}
+		if _, cf := findPage(tree, clean); cf != nil {
+			return true
+		}
+		if _, ok := indexHTML(tree, clean); ok {
+			return true
+		}
+		_, _, _, _, ok := findArchive(tree, clean)
+		return ok
+	case "/public/":
+		name := clean[len(pattern):]
+		assetsLock.RLock()
+		if original, ok := fingerprinted[name]; ok {
+			name = original
+		}
+		assetsLock.RUnlock()
+		return fileExists(filepath.Join(public, filepath.FromSlash(name)))
+	case "/downloads/":
+		return fileExists(filepath.Join(root, *optDownloadsDir, filepath.FromSlash(clean[len(pattern):])))
+	case "/favicon.ico", "/robots.txt", "/manifest.json", "/service-worker.js":
+		return fileExists(filepath.Join(public, pattern[1:]))
+	}
+
+	// the routes generated by the server, such as /sitemap.xml and /search
+	return pattern != ""
+}
+
+func fileExists(name string) bool {
+	info, err := statFile(name)
+	return err == nil && !info.IsDir()
+}
+
+func externalLinkExists(client *http.Client, u string) bool {
+	resp, err := client.Head(u)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = client.Get(u)
+	}
+	if err != nil {
+		return false
+	}
+
+	resp.Body.Close()
+	return resp.StatusCode < 400
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckLinksReportsMissingPages(t *testing.T) {
+	setFlag(t, "check-external", "false")
+	d := testSite(t, map[string]string{
+		"index.md":  "[about](/about) [missing](/missing) [image](/public/missing.png)",
+		"about.md":  "[home](/) [top](#top)",
+		"redirects": "/old /about\n",
+	})
+	logged := captureLog(t)
+
+	if broken := checkLinks(newMux(), d); broken != 2 {
+		t.Errorf("%v broken links, want 2", broken)
+	}
+	for _, href := range []string{"/missing", "/public/missing.png"} {
+		if !strings.Contains(logged.String(), "Broken link in /: "+href) {
+			t.Errorf("log %q does not report %v", logged, href)
+		}
+	}
+}
+
+func TestInternalLinksResolveAsServed(t *testing.T) {
+	setFlag(t, "canonical-slash", "strip")
+	setFlag(t, "downloads", "downloads")
+	d := testSite(t, map[string]string{
+		"about.md":            "About",
+		"blog/post.md":        "---\ndate: 2023-06-01\n---\nPost",
+		"files/index.html":    "<p>Files</p>",
+		".public/style.css":   "body {}",
+		"redirects":           "/old /about\n",
+		".public/robots.txt":  "User-agent: *",
+		"downloads/notes.txt": "notes",
+	})
+	mux := newMux()
+
+	style, err := assetURL("style.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"/about", "/about/", "/old", "/files/", "/blog/2023/", "/blog/2023/06/", "/sitemap.xml", "/robots.txt", "/public/style.css", style, "/downloads/notes.txt"} {
+		if !internalLinkExists(mux, d, p) {
+			t.Errorf("link to %v is reported as broken", p)
+		}
+	}
+	for _, p := range []string{"/missing", "/news/2023/", "/files/missing/", "/public/nope.css", "/favicon.ico"} {
+		if internalLinkExists(mux, d, p) {
+			t.Errorf("link to %v is not reported as broken", p)
+		}
+	}
+}
+
+func TestCheckLinksResolvesAsLinked(t *testing.T) {
+	setFlag(t, "check-external", "false")
+	setFlag(t, "canonical-slash", "add")
+	d := testSite(t, map[string]string{
+		"blog/post.md":  "[other](../other) [elsewhere](//example.com/missing)",
+		"blog/other.md": "Other",
+	})
+	logged := captureLog(t)
+
+	if broken := checkLinks(newMux(), d); broken != 0 {
+		t.Errorf("%v broken links, want 0: %q", broken, logged)
+	}
+}
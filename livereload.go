@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"sync"
+)
+
+var optDev = flag.Bool("dev", false, "reload pages open in browsers when they change; use with -watch")
+
+const liveReloadScript = `<script>new EventSource("/livereload").addEventListener("reload", function() { location.reload() })</script>`
+
+var liveReloadClients = make(map[chan struct{}]bool)
+var liveReloadLock sync.Mutex
+
+// setupLiveReload serves /livereload, a stream of server-sent events
+// with a "reload" event each time the pages are reloaded.
+func setupLiveReload(mux *http.ServeMux) {
+	if !*optDev {
+		return
+	}
+
+	mux.HandleFunc("/livereload", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		c := make(chan struct{}, 1)
+		liveReloadLock.Lock()
+		liveReloadClients[c] = true
+		liveReloadLock.Unlock()
+
+		defer func() {
+			liveReloadLock.Lock()
+			delete(liveReloadClients, c)
+			liveReloadLock.Unlock()
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte(": connected\n\n"))
+		flusher.Flush()
+
+		for {
+			select {
+			case <-c:
+				w.Write([]byte("event: reload\ndata: \n\n"))
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
+
+func notifyLiveReload() {
+	liveReloadLock.Lock()
+	defer liveReloadLock.Unlock()
+
+	for c := range liveReloadClients {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// injectLiveReload places the live reload script before the closing body
+// tag of a top level layout, or at its end.
+func injectLiveReload(parts []LayoutPart) []LayoutPart {
+	for i := len(parts) - 1; i >= 0; i-- {
+		if j := bytes.LastIndex(parts[i].Text, []byte("</body>")); j >= 0 {
+			text := append(append(append([]byte(nil), parts[i].Text[:j]...), liveReloadScript...), parts[i].Text[j:]...)
+			parts[i] = LayoutPart{Text: text}
+			return parts
+		}
+	}
+
+	return append(parts, LayoutPart{Text: []byte(liveReloadScript)})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLiveReloadScript(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About"})
+	if body := get(http.HandlerFunc(renderPage), "/about").Body.String(); strings.Contains(body, "livereload") {
+		t.Errorf("page without -dev %q has the live reload script", body)
+	}
+
+	setFlag(t, "dev", "true")
+	testSite(t, map[string]string{"about.md": "About"})
+	if body := get(http.HandlerFunc(renderPage), "/about").Body.String(); !strings.Contains(body, liveReloadScript+"</body>") {
+		t.Errorf("page with -dev %q does not have the live reload script before </body>", body)
+	}
+}
+
+func TestLiveReloadEvents(t *testing.T) {
+	setFlag(t, "dev", "true")
+	testSite(t, map[string]string{"about.md": "About"})
+	srv := httptest.NewServer(testHandler())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/livereload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+
+	events := bufio.NewReader(res.Body)
+	if line, err := events.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("first line %q, %v", line, err)
+	}
+	events.ReadString('\n')
+
+	notifyLiveReload()
+	if line, err := events.ReadString('\n'); err != nil || line != "event: reload\n" {
+		t.Errorf("line after a reload %q, %v", line, err)
+	}
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
@@ -13,77 +16,333 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
+
+	"github.com/andybalholm/brotli"
 )
 
 var optAddr = flag.String("addr", ":8000", "address to listen on")
 var optPath = flag.String("path", ".", "path of the static files to serve")
 var optStaticDir = flag.String("public", ".public", "path of the 'public' directory")
+var optNotFound = flag.String("notfound", "404", "name of the content page served when a page is not found")
+var optVerboseErrors = flag.Bool("verbose-errors", false, "show the detail of server errors in error pages, as well as logging it")
+var optShowDrafts = flag.Bool("show-drafts", false, "serve pages marked as drafts in their front matter")
+var optCacheControl = flag.String("cache-control", "public, max-age=0, must-revalidate", "Cache-Control header sent with rendered pages")
+var optStaticCacheControl = flag.String("static-cache-control", "public, max-age=86400", "Cache-Control header sent with static files")
+var optShutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "time to wait for in-flight requests when shutting down")
+var optMaxPath = flag.Int("max-path", 2048, "longest request path served; longer paths get a 414 response")
+var optReadHeaderTimeout = flag.Duration("read-header-timeout", 10*time.Second, "time allowed to read a request's headers")
+var optReadTimeout = flag.Duration("read-timeout", 30*time.Second, "time allowed to read a whole request")
+var optWriteTimeout = flag.Duration("write-timeout", 60*time.Second, "time allowed to write a response")
+var optIdleTimeout = flag.Duration("idle-timeout", 120*time.Second, "time a keep-alive connection may wait for the next request")
 
 var root string
+
 var tree *Dir
+var treeLock sync.RWMutex
+
+// loadLock is held while the pages are loaded, so that the options read
+// while loading them are not changed part way through.
+var loadLock sync.Mutex
 
 func main() {
 	flag.Parse()
 
 	var err error
 
+	if err = loadOptions(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
 	if root, err = filepath.Abs(*optPath); err != nil {
 		log.Fatalf("Could not get the absolute path of %v. %v", *optPath, err)
 	}
 
-	setupStaticDir()
-	setupSignals()
+	if err = setupFS(); err != nil {
+		log.Fatalf("%v", err)
+	}
 
-	var ok bool
-	if tree, ok = readTree(); !ok {
-		log.Fatalf("Exiting...")
+	if err = checkOptions(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	setupTimezone()
+
+	if *optPreviewLink != "" {
+		printPreviewLink()
+		return
+	}
+
+	mux := newMux()
+	srv := &http.Server{Addr: *optAddr, Handler: newHandler(mux)}
+	servers := []*http.Server{srv}
+	done := make(chan struct{})
+
+	redirect := setupTLS(srv)
+	setupH2C(srv)
+	if redirect != nil {
+		servers = append(servers, redirect)
 	}
 
-	http.HandleFunc("/", renderPage)
+	metrics := setupMetrics()
+	if metrics != nil {
+		servers = append(servers, metrics)
+	}
+
+	setTimeouts(servers)
+	setupSignals(servers, done)
+	setupWatch()
+	setupS3Refresh()
+
+	if *optExport != "" || *optCheckLinks {
+		d, ok := readTree()
+		if !ok {
+			log.Fatalf("Exiting...")
+		}
+		setTree(d)
 
-	if err = http.ListenAndServe(*optAddr, nil); err != nil {
-		log.Fatalf("Could not serve static files at path %v. %v", root, err)
+		if *optExport != "" {
+			if err := export(mux, d, *optExport); err != nil {
+				log.Fatalf("Could not export the site to %v. %v", *optExport, err)
+			}
+			return
+		}
+
+		if checkLinks(mux, d) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if redirect != nil {
+		go func() {
+			if err := redirect.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("Could not listen for HTTP redirects at %v. %v", redirect.Addr, err)
+			}
+		}()
 	}
+
+	if metrics != nil {
+		go func() {
+			if err := metrics.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("Could not serve metrics at %v. %v", metrics.Addr, err)
+			}
+		}()
+	}
+
+	// listening while the pages load, with /readyz answering 503 until
+	// they have
+	go func() {
+		if err := serve(srv); err != http.ErrServerClosed {
+			log.Fatalf("Could not serve static files at path %v. %v", root, err)
+		}
+	}()
+
+	// a HUP may already be reloading the config file
+	loadLock.Lock()
+	d, ok := readTree()
+	if ok {
+		setTree(d)
+	}
+	loadLock.Unlock()
+	if !ok {
+		log.Fatalf("Exiting...")
+	}
+
+	<-done
+}
+
+// newMux registers the routes the options call for on a new ServeMux.
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	setupHealth(mux)
+	setupDebug(mux)
+	setupPurge(mux)
+	setupStaticDir(mux)
+	setupDownloads(mux)
+	setupSitemap(mux)
+	setupSearch(mux)
+	setupHighlight(mux)
+	setupLiveReload(mux)
+	mux.HandleFunc("/", renderPage)
+
+	return mux
 }
 
-func setupStaticDir() {
+// newHandler wraps the routes in the handling every request goes through.
+func newHandler(mux *http.ServeMux) http.Handler {
+	return requestID(accessLog(whenReady(rateLimit(corsPreflight(mux, basicAuth(mux))))))
+}
+
+func setupStaticDir(mux *http.ServeMux) {
 	public := filepath.Join(root, *optStaticDir)
 
-	h := http.StripPrefix("/public/", http.FileServer(http.Dir(public)))
+	h := http.StripPrefix("/public/", serveAssets(dirListing(public, compressStatic(public, staticETag(public, http.FileServer(http.FS(subFS(public))))))))
+
+	mux.Handle("/public/", staticCacheControl(h))
+	mux.Handle("/favicon.ico", staticCacheControl(staticETag(public, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, fsys, sitePath(filepath.Join(public, "favicon.ico")))
+	}))))
+	mux.Handle("/robots.txt", staticCacheControl(staticETag(public, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, fsys, sitePath(filepath.Join(public, "robots.txt")))
+	}))))
+	mux.Handle("/manifest.json", staticCacheControl(staticETag(public, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/manifest+json")
+		http.ServeFileFS(w, r, fsys, sitePath(filepath.Join(public, "manifest.json")))
+	}))))
+	// browsers check for a new service worker on each visit
+	mux.Handle("/service-worker.js", staticETag(public, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Type", "text/javascript; charset=UTF-8")
+		http.ServeFileFS(w, r, fsys, sitePath(filepath.Join(public, "service-worker.js")))
+	})))
+}
 
-	http.Handle("/public/", h)
-	http.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(public, "favicon.ico"))
-	})
-	http.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(public, "robots.txt"))
+func staticCacheControl(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *optStaticCacheControl != "" {
+			w.Header().Set("Cache-Control", *optStaticCacheControl)
+		}
+		h.ServeHTTP(w, r)
 	})
 }
 
-func setupSignals() {
+// checkOptions validates the options which are not checked when parsed.
+func checkOptions() error {
+	if !validLogFormat(*optLogFormat) {
+		return fmt.Errorf("Unknown log format '%v'", *optLogFormat)
+	}
+
+	if _, err := time.LoadLocation(*optTimezone); err != nil {
+		return fmt.Errorf("Unknown time zone '%v'. %v", *optTimezone, err)
+	}
+
+	if !validRenderer(*optRenderer) {
+		return fmt.Errorf("Unknown renderer '%v'", *optRenderer)
+	}
+
+	if !validExtensions(*optExt) {
+		return fmt.Errorf("Unknown page extension in -ext '%v'", *optExt)
+	}
+
+	if !validCanonicalSlash(*optCanonicalSlash) {
+		return fmt.Errorf("Unknown -canonical-slash policy '%v'", *optCanonicalSlash)
+	}
+
+	if *optAuthPrefix != "" && (*optAuthUser == "" || *optAuthPass == "") {
+		return errors.New("-auth-prefix requires -auth-user and -auth-pass")
+	}
+
+	return nil
+}
+
+func setupSignals(servers []*http.Server, done chan<- struct{}) {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGUSR1)
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		for {
-			<-c
-
-			log.Print("\nReloading...")
-			var d *Dir
-			var ok bool
-
-			if d, ok = readTree(); !ok {
-				log.Println("Reload unsuccessful")
-			} else {
-				//lock
-				tree = d
+			switch <-c {
+			case syscall.SIGUSR1:
+				reload()
+				continue
+			case syscall.SIGHUP:
+				log.Print("\nReloading the config file...")
+				if err := reloadConfig(); err != nil {
+					log.Printf("Config reload unsuccessful: %v\n", err)
+				}
+				reload()
+				continue
 			}
+
+			shutdown(servers)
+			close(done)
+			return
 		}
 	}()
 }
 
+func shutdown(servers []*http.Server) {
+	log.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), *optShutdownTimeout)
+	defer cancel()
+
+	clean := true
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown of %v did not complete cleanly: %v\n", srv.Addr, err)
+			clean = false
+		}
+	}
+
+	if clean {
+		log.Println("Shutdown complete")
+	}
+}
+
+func reload() bool {
+	loadLock.Lock()
+	defer loadLock.Unlock()
+
+	log.Print("\nReloading...")
+	var d *Dir
+	var ok bool
+
+	if d, ok = readTree(); !ok {
+		log.Println("Reload unsuccessful")
+	} else {
+		setTree(d)
+		notifyLiveReload()
+	}
+	return ok
+}
+
+func getTree() *Dir {
+	treeLock.RLock()
+	defer treeLock.RUnlock()
+	return tree
+}
+
+// setTimeouts limits the time the servers' clients have to send requests
+// and read responses, so slow clients cannot hold connections open.
+func setTimeouts(servers []*http.Server) {
+	for _, s := range servers {
+		s.ReadHeaderTimeout = *optReadHeaderTimeout
+		s.ReadTimeout = *optReadTimeout
+		s.WriteTimeout = *optWriteTimeout
+		s.IdleTimeout = *optIdleTimeout
+	}
+}
+
+func setTree(d *Dir) {
+	updateSitemap(d)
+	updateSearch(d)
+
+	treeLock.Lock()
+	defer treeLock.Unlock()
+	tree = d
+	loadedAt = time.Now()
+}
+
 func readTree() (*Dir, bool) {
+	if err := updateAssets(); err != nil {
+		log.Printf("Could not fingerprint the public directory. %v\n", err)
+		return nil, false
+	}
+
+	if err := updateSite(); err != nil {
+		log.Printf("Could not read the site file '%v'. %v\n", filepath.Join(root, SiteFilename), err)
+		return nil, false
+	}
+
+	if err := updateIndexTemplate(); err != nil {
+		log.Printf("Could not read the index template. %v\n", err)
+		return nil, false
+	}
+
 	var dir *Dir
 	var errs []error
 	if dir, errs = ReadTree(root); len(errs) > 0 {
@@ -99,73 +358,360 @@ func readTree() (*Dir, bool) {
 }
 
 func renderPage(w http.ResponseWriter, r *http.Request) {
-	p, file := filepath.Split(r.URL.Path)
+	start := time.Now()
+	defer func() {
+		renderDuration.Observe(time.Since(start).Seconds())
+	}()
 
-	if file == "" {
-		file = "index"
+	tree := getTree()
+
+	if len(r.URL.Path) > *optMaxPath {
+		writeError(w, r, tree, 414, "Request path too long!")
+		return
 	}
 
-	if d := tree.FindByPath(p); d != nil {
-		if cf, ok := d.Files[file]; ok {
-			write(w, r, 200, cf.Content, cf.Hash, d.Layout)
+	for _, seg := range strings.Split(r.URL.Path, "/") {
+		if seg == ".." {
+			writeError(w, r, tree, 400, "Bad request!")
 			return
 		}
 	}
 
-	write(w, r, 404, []byte("<h2>Oops! We've hit a bit of a problem...</h2><p>Page not found!</p>"), nil, tree.Layout)
+	if to, code, ok := tree.Redirects.Find(r.URL.Path); ok {
+		if r.URL.RawQuery != "" && !strings.Contains(to, "?") {
+			to += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, to, code)
+		return
+	}
+
+	if to, ok := canonicalPath(tree, r.URL.Path); ok {
+		if r.URL.RawQuery != "" {
+			to += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, to, 301)
+		return
+	}
+
+	if d, cf := findPage(tree, r.URL.Path, requestLanguages(r)...); cf != nil {
+		pageLookups.WithLabelValues("hit").Inc()
+		if wantsJSON(r) {
+			writeJSON(w, r, r.URL.Path, cf)
+			return
+		}
+		if wantsText(r) {
+			writeText(w, r, cf)
+			return
+		}
+		write(w, r, 200, cf, viewLayout(r, d, pageLayout(d, cf)))
+		return
+	}
+
+	if validPreview(r) {
+		if d, cf := lookupPage(tree, r.URL.Path, true, requestLanguages(r)); cf != nil {
+			pageLookups.WithLabelValues("hit").Inc()
+			// a copy, so as not to change the cached page
+			preview := *cf
+			preview.CacheControl = "private, no-store"
+			w.Header().Set("X-Robots-Tag", "noindex")
+			write(w, r, 200, &preview, viewLayout(r, d, pageLayout(d, &preview)))
+			return
+		}
+	}
+
+	if p, ok := indexHTML(tree, r.URL.Path); ok {
+		pageLookups.WithLabelValues("hit").Inc()
+		if *optStaticCacheControl != "" {
+			w.Header().Set("Cache-Control", *optStaticCacheControl)
+		}
+		http.ServeFileFS(w, r, fsys, sitePath(p))
+		return
+	}
+
+	if p, d, year, month, ok := findArchive(tree, r.URL.Path); ok {
+		pageLookups.WithLabelValues("hit").Inc()
+		writeArchive(w, r, p, d, year, month)
+		return
+	}
+
+	pageLookups.WithLabelValues("miss").Inc()
+
+	writeError(w, r, tree, 404, "Page not found!")
 }
 
-type nCloseWriter struct {
-	io.Writer
+// findPage returns the page served at the URL path p, in the first of
+// langs it is available in, and its directory, or nil if there is no such
+// page. With -canonical-slash=add, a path with a trailing slash falls back
+// to the page of that name.
+func findPage(tree *Dir, p string, langs ...string) (*Dir, *ContentFile) {
+	return lookupPage(tree, p, *optShowDrafts, langs)
 }
 
-func (w nCloseWriter) Close() error {
-	return nil
+// lookupPage finds the page at p, including drafts if drafts is set.
+func lookupPage(tree *Dir, p string, drafts bool, langs []string) (*Dir, *ContentFile) {
+	dir, file := filepath.Split(p)
+	d := tree.FindByPath(dir)
+
+	if file == "" {
+		file = d.defaultName()
+	}
+
+	if d != nil {
+		if cf := d.findVariant(file, langs, drafts); cf != nil {
+			return d, cf
+		}
+	}
+
+	if strings.HasSuffix(p, "/") && p != "/" && *optCanonicalSlash == "add" {
+		return lookupPage(tree, strings.TrimRight(p, "/"), drafts, langs)
+	}
+
+	return nil, nil
 }
-func NoOpCloseWriter(w io.Writer) io.WriteCloser {
-	return nCloseWriter{w}
+
+// indexHTML returns the path of an index.html file in the directory at
+// the URL path p, served as it is when the directory has no index page.
+func indexHTML(tree *Dir, p string) (string, bool) {
+	if !strings.HasSuffix(p, "/") || tree.FindByPath(p) == nil {
+		return "", false
+	}
+
+	f := filepath.Join(root, filepath.FromSlash(p), "index.html")
+	if info, err := statFile(f); err != nil || info.IsDir() {
+		return "", false
+	}
+	return f, true
 }
 
-func write(w http.ResponseWriter, r *http.Request, statusCode int, value []byte, hash []byte, layout *LayoutFile) {
-	if len(hash) > 0 {
-		h := make([]byte, 16)
-		copy(h, hash)
+func writeError(w http.ResponseWriter, r *http.Request, tree *Dir, statusCode int, message string) {
+	if statusCode >= 500 {
+		log.Printf("%v %v %v: %v\n", statusCode, r.URL.Path, getRequestID(r), message)
+		if !*optVerboseErrors {
+			message = "Something went wrong on our side. Please try again later."
+		}
+	}
 
-		if layout != nil {
-			for i := 0; i < 16; i++ {
-				h[i] ^= layout.Hash[i]
-			}
+	if page, ok := tree.ErrorPages[statusCode]; ok {
+		cf := &ContentFile{Content: page.Content, ContentType: page.ContentType, Raw: page.Raw}
+		cf.FrontMatter = page.FrontMatter
+		write(w, r, statusCode, cf, pageLayout(tree, cf))
+		return
+	}
+
+	if statusCode == 404 {
+		if page, ok := tree.Files[*optNotFound]; ok {
+			// no hash; the error page should not be answered with a 304
+			cf := &ContentFile{Content: page.Content, ContentType: page.ContentType, Raw: page.Raw,
+				Layout: page.Layout, Prerendered: page.Prerendered, Size: page.Size, Rendered: page.Rendered,
+				GzipContent: page.GzipContent, BrotliContent: page.BrotliContent}
+			write(w, r, statusCode, cf, pageLayout(tree, cf))
+			return
 		}
+	}
 
-		strHash := fmt.Sprintf("%x", h)
+	cf := &ContentFile{Content: []byte("<h2>Oops! We've hit a bit of a problem...</h2><p>" + html.EscapeString(message) + "</p>")}
+	write(w, r, statusCode, cf, tree.Layout)
+}
+
+func writeLayout(w io.Writer, layout *LayoutFile, cf *ContentFile) {
+	if *optMinify && !cf.Raw && strings.HasPrefix(pageContentType(cf), "text/html") {
+		b := &bytes.Buffer{}
+		renderLayout(b, layout, cf)
+		w.Write(minifyHTML(b.Bytes()))
+		return
+	}
+
+	renderLayout(w, layout, cf)
+}
+
+func renderLayout(w io.Writer, layout *LayoutFile, cf *ContentFile) {
+	if layout != nil {
+		layout.Render(w, cf)
+	} else {
+		w.Write(cf.Content)
+	}
+}
 
-		if etag := r.Header.Get("If-None-Match"); strings.EqualFold(etag, strHash) {
+func compressBytes(encoding string, b []byte) []byte {
+	out := &bytes.Buffer{}
+	var cw io.WriteCloser
+	if encoding == "br" {
+		cw = brotli.NewWriter(out)
+	} else {
+		cw = gzip.NewWriter(out)
+	}
+	cw.Write(b)
+	cw.Close()
+	return out.Bytes()
+}
+
+// streamPage writes the page as it is compressed, for pages too large to
+// be worth holding in memory in full.
+func streamPage(w io.Writer, encoding string, layout *LayoutFile, cf *ContentFile) {
+	switch encoding {
+	case "br":
+		br := brotli.NewWriter(w)
+		writeLayout(br, layout, cf)
+		br.Close()
+	case "gzip":
+		gz := gzip.NewWriter(w)
+		writeLayout(gz, layout, cf)
+		gz.Close()
+	default:
+		writeLayout(w, layout, cf)
+	}
+}
+
+func pageContentType(cf *ContentFile) string {
+	if cf.ContentType != "" {
+		return cf.ContentType
+	}
+	return *optContentType
+}
+
+// pageETag identifies the rendered output of a page, which depends on both
+// its content and the layout, and view, it is rendered within.
+func pageETag(cf *ContentFile, layout *LayoutFile) string {
+	if layout == nil {
+		return fmt.Sprintf("%x", cf.Hash)
+	}
+
+	b := append(append([]byte(nil), cf.Hash...), layout.Hash...)
+	if layout.View != "" {
+		b = append(b, layout.View...)
+	}
+	return fmt.Sprintf("%x", hash(b))
+}
+
+// pageModTime is the time the page or its layout last changed.
+func pageModTime(cf *ContentFile, layout *LayoutFile) time.Time {
+	if layout != nil && layout.ModTime.After(cf.ModTime) {
+		return layout.ModTime
+	}
+	return cf.ModTime
+}
+
+// notModified reports whether the client's cached copy is current. As in
+// RFC 7232, If-Modified-Since is only considered without If-None-Match.
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return strings.EqualFold(inm, etag)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modTime.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
+
+func write(w http.ResponseWriter, r *http.Request, statusCode int, cf *ContentFile, layout *LayoutFile) {
+	if cc := cf.CacheControl; cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	} else if *optCacheControl != "" {
+		w.Header().Set("Cache-Control", *optCacheControl)
+	}
+
+	if len(cf.Hash) > 0 {
+		strHash := pageETag(cf, layout)
+		modTime := pageModTime(cf, layout)
+
+		w.Header().Set("Etag", strHash)
+		if !modTime.IsZero() {
+			w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+		}
+
+		if notModified(r, strHash, modTime) {
 			w.WriteHeader(http.StatusNotModified)
 			return
 		}
+	}
 
-		w.Header().Set("Etag", strHash)
+	w.Header().Set("Vary", "Accept, Accept-Encoding, Accept-Language")
+
+	if cf.Lang != "" {
+		w.Header().Set("Content-Language", cf.Lang)
+	} else if *optDefaultLang != "" {
+		w.Header().Set("Content-Language", *optDefaultLang)
 	}
 
-	b := &bytes.Buffer{}
-	var writer io.WriteCloser = NoOpCloseWriter(b)
-	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-		w.Header().Set("Content-Encoding", "gzip")
-		writer = gzip.NewWriter(b)
+	var timing serverTiming
+
+	// pages are rendered once in their own layout, as the tree is loaded
+	cached := cf.Prerendered && cf.Layout == layout
+	timing.cache(cached)
+
+	size, body := cf.Size, cf.Rendered
+	if !cached {
+		start := time.Now()
+		b := &bytes.Buffer{}
+		writeLayout(b, layout, cf)
+		size, body = b.Len(), b.Bytes()
+		timing.since("render", start)
 	}
 
-	if layout != nil {
-		writer.Write(layout.Pre)
-		writer.Write(value)
-		writer.Write(layout.Post)
+	encoding := ""
+	if size > *optGzipMinBytes {
+		encoding = acceptEncoding(r.Header.Get("Accept-Encoding"))
+	}
+
+	if encoding == "" {
+		responseEncodings.WithLabelValues("identity").Inc()
 	} else {
-		writer.Write(value)
+		responseEncodings.WithLabelValues(encoding).Inc()
+		w.Header().Set("Content-Encoding", encoding)
 	}
 
-	writer.Close()
+	w.Header().Set("Content-Type", pageContentType(cf))
+	setSecurityHeaders(w.Header())
+
+	if streamed(size) && encoding == "" && statusCode == 200 {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Header.Get("Range") != "" {
+			// ServeContent only matches quoted ETags in If-Range
+			if ir := r.Header.Get("If-Range"); ir != "" && strings.EqualFold(ir, w.Header().Get("Etag")) {
+				r = r.Clone(r.Context())
+				r.Header.Del("If-Range")
+			}
+
+			// a streamed page is only held in full for range requests
+			if body == nil {
+				b := &bytes.Buffer{}
+				writeLayout(b, layout, cf)
+				body = b.Bytes()
+			}
+			timing.set(w.Header())
+			http.ServeContent(w, r, "", pageModTime(cf, layout), bytes.NewReader(body))
+			return
+		}
+	}
+
+	if body == nil {
+		// the page is too large to have been kept, so is streamed
+		timing.set(w.Header())
+		w.WriteHeader(statusCode)
+		streamPage(w, encoding, layout, cf)
+		return
+	}
+
+	if encoding != "" {
+		compressed := cf.GzipContent
+		if encoding == "br" {
+			compressed = cf.BrotliContent
+		}
+
+		if cached && compressed != nil {
+			body = compressed
+		} else {
+			start := time.Now()
+			body = compressBytes(encoding, body)
+			timing.since("compress", start)
+		}
+	}
+	timing.set(w.Header())
 
-	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
-	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
 	w.WriteHeader(statusCode)
-	b.WriteTo(w)
+	w.Write(body)
 }
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const testLayout = "<html><head><title>{{title}}</title></head><body>{{content}}</body></html>"
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// writeSite writes the files, keyed by slash separated path, beneath a new
+// directory and makes it the root.
+func writeSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := root
+	root = dir
+	t.Cleanup(func() { root = previous })
+	return dir
+}
+
+// loadSite reads the tree at the root and serves it, as the server does
+// at startup.
+func loadSite(t *testing.T) *Dir {
+	t.Helper()
+
+	d, ok := readTree()
+	if !ok {
+		t.Fatal("could not load the site")
+	}
+	setTree(d)
+	t.Cleanup(func() { setTree(nil) })
+	return d
+}
+
+// testSite writes and loads a site with the files.
+func testSite(t *testing.T, files map[string]string) *Dir {
+	t.Helper()
+
+	if _, ok := files["layout.html"]; !ok {
+		files["layout.html"] = testLayout
+	}
+	writeSite(t, files)
+	return loadSite(t)
+}
+
+// setFlag sets the named option for the rest of the test.
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+
+	previous := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flag.Set(name, previous) })
+}
+
+// captureLog collects what is logged for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	b := &bytes.Buffer{}
+	log.SetOutput(b)
+	t.Cleanup(func() {
+		if testing.Verbose() {
+			log.SetOutput(os.Stderr)
+		} else {
+			log.SetOutput(io.Discard)
+		}
+	})
+	return b
+}
+
+// testHandler returns the server's handler, with the routes the options
+// currently call for.
+func testHandler() http.Handler {
+	return newHandler(newMux())
+}
+
+// get requests the URL path through h, with headers given as name, value
+// pairs.
+func get(h http.Handler, u string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", u, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRenderPageWrapsContentInLayout(t *testing.T) {
+	testSite(t, map[string]string{
+		"layout.html": "<header>top</header>{{content}}<footer>bottom</footer>",
+		"about.md":    "About *us*",
+	})
+
+	w := get(http.HandlerFunc(renderPage), "/about")
+	if w.Code != 200 {
+		t.Fatalf("status %v, want 200", w.Code)
+	}
+
+	body := w.Body.String()
+	top, content, bottom := strings.Index(body, "<header>top</header>"), strings.Index(body, "About <em>us</em>"), strings.Index(body, "<footer>bottom</footer>")
+	if top < 0 || content < 0 || bottom < 0 || !(top < content && content < bottom) {
+		t.Errorf("body %q does not hold the layout's halves around the content", body)
+	}
+}
+
+func TestRenderPageConcurrently(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About *us*"})
+	h := testHandler()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := get(h, "/about", "Accept-Encoding", "gzip")
+			if w.Code != 200 {
+				t.Errorf("status %v, want 200", w.Code)
+			}
+		}()
+		if i == 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				reload()
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func TestRenderPageRejectsTraversal(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About"})
+
+	for _, u := range []string{"/../about", "/docs/../../etc/passwd", "/%2e%2e/about", "/docs/%2E%2E/%2e%2e/etc/passwd"} {
+		// as sent, without the cleaning ServeMux does
+		r := httptest.NewRequest("GET", "/", nil)
+		var err error
+		if r.URL, err = url.Parse(u); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		renderPage(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: status %v, want 400", u, w.Code)
+		}
+	}
+
+	if w := get(http.HandlerFunc(renderPage), "/about"); w.Code != http.StatusOK {
+		t.Errorf("/about: status %v, want 200", w.Code)
+	}
+}
+
+func TestShutdownOnSIGTERM(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+
+	done := make(chan struct{})
+	setupSignals([]*http.Server{srv}, done)
+	defer signal.Reset()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not shut down after SIGTERM")
+	}
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("Serve returned %v, want http.ErrServerClosed", err)
+	}
+}
+
+func TestDraftsHiddenUnlessShown(t *testing.T) {
+	testSite(t, map[string]string{
+		"draft.md":     "---\ndraft: true\n---\nNot yet",
+		"blog/post.md": "---\ndate: 2023-06-01\ndraft: true\n---\nNot yet",
+	})
+	h := testHandler()
+
+	for _, u := range []string{"/draft", "/blog/post"} {
+		if w := get(h, u); w.Code != http.StatusNotFound {
+			t.Errorf("%v: status %v, want 404 for a draft", u, w.Code)
+		}
+	}
+	if body := get(h, "/blog/2023/").Body.String(); strings.Contains(body, "/blog/post") {
+		t.Errorf("archive %q lists a draft", body)
+	}
+
+	setFlag(t, "show-drafts", "true")
+	for _, u := range []string{"/draft", "/blog/post"} {
+		if w := get(h, u); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Not yet") {
+			t.Errorf("%v with -show-drafts: status %v, body %q", u, w.Code, w.Body)
+		}
+	}
+	if body := get(h, "/blog/2023/").Body.String(); !strings.Contains(body, "/blog/post") {
+		t.Errorf("archive %q with -show-drafts does not list the draft", body)
+	}
+}
+
+func TestETagChangesWithLayout(t *testing.T) {
+	writeSite(t, map[string]string{"layout.html": "<main>{{content}}</main>", "about.md": "About"})
+	loadSite(t)
+	h := http.HandlerFunc(renderPage)
+
+	first := get(h, "/about").Header().Get("Etag")
+	if first == "" {
+		t.Fatal("no Etag")
+	}
+	if again := get(h, "/about").Header().Get("Etag"); again != first {
+		t.Errorf("Etag %v changed to %v without a change", first, again)
+	}
+	if w := get(h, "/about", "If-None-Match", first); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match %v: status %v, want 304", first, w.Code)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "layout.html"), []byte("<article>{{content}}</article>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loadSite(t)
+	if changed := get(h, "/about").Header().Get("Etag"); changed == first {
+		t.Errorf("Etag %v unchanged after the layout changed", changed)
+	}
+}
+
+func TestIfModifiedSince(t *testing.T) {
+	dir := writeSite(t, map[string]string{"layout.html": testLayout, "about.md": "About"})
+	modified := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, n := range []string{"layout.html", "about.md"} {
+		if err := os.Chtimes(filepath.Join(dir, n), modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loadSite(t)
+	h := http.HandlerFunc(renderPage)
+
+	w := get(h, "/about")
+	if w.Code != http.StatusOK || w.Header().Get("Last-Modified") != modified.Format(http.TimeFormat) {
+		t.Fatalf("status %v, Last-Modified %q; want 200 and %v", w.Code, w.Header().Get("Last-Modified"), modified.Format(http.TimeFormat))
+	}
+
+	for since, want := range map[time.Time]int{
+		modified:                 http.StatusNotModified,
+		modified.Add(time.Hour):  http.StatusNotModified,
+		modified.Add(-time.Hour): http.StatusOK,
+	} {
+		if w := get(h, "/about", "If-Modified-Since", since.Format(http.TimeFormat)); w.Code != want {
+			t.Errorf("If-Modified-Since %v: status %v, want %v", since, w.Code, want)
+		}
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	setFlag(t, "cache-control", "public, max-age=60")
+	setFlag(t, "static-cache-control", "public, max-age=3600")
+	testSite(t, map[string]string{
+		"about.md":           "About",
+		"fresh.md":           "---\ncache-control: no-cache\n---\nFresh",
+		".public/site.css":   "body {}",
+		".public/robots.txt": "User-agent: *",
+	})
+	h := testHandler()
+
+	for u, want := range map[string]string{
+		"/about":           "public, max-age=60",
+		"/fresh":           "no-cache",
+		"/public/site.css": "public, max-age=3600",
+		"/robots.txt":      "public, max-age=3600",
+	} {
+		if cc := get(h, u).Header().Get("Cache-Control"); cc != want {
+			t.Errorf("%v: Cache-Control %q, want %q", u, cc, want)
+		}
+	}
+}
+
+func TestIndexHTMLServedWithoutIndexPage(t *testing.T) {
+	setFlag(t, "static-cache-control", "max-age=60")
+	testSite(t, map[string]string{
+		"old/index.html":  "<html>old site</html>",
+		"blog/index.html": "<html>old blog</html>",
+		"blog/index.md":   "New blog",
+	})
+
+	w := get(http.HandlerFunc(renderPage), "/old/")
+	if w.Code != 200 || w.Body.String() != "<html>old site</html>" {
+		t.Errorf("/old/: status %v, body %q", w.Code, w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "max-age=60" {
+		t.Errorf("/old/: Cache-Control %q", cc)
+	}
+
+	if body := get(http.HandlerFunc(renderPage), "/blog/").Body.String(); !strings.Contains(body, "New blog") {
+		t.Errorf("/blog/ body %q, want the index page rather than index.html", body)
+	}
+	if w := get(http.HandlerFunc(renderPage), "/missing/"); w.Code != 404 {
+		t.Errorf("/missing/: status %v, want 404", w.Code)
+	}
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	setFlag(t, "read-header-timeout", "100ms")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	setTimeouts([]*http.Server{srv})
+	go srv.Serve(l)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// headers that never finish
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("connection not closed by the server: %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("connection closed after %v, want about 100ms", d)
+	}
+}
+
+func TestMaxPath(t *testing.T) {
+	setFlag(t, "max-path", "20")
+	testSite(t, map[string]string{"about.md": "About"})
+
+	if w := get(http.HandlerFunc(renderPage), "/about"); w.Code != 200 {
+		t.Errorf("/about: status %v, want 200", w.Code)
+	}
+	if w := get(http.HandlerFunc(renderPage), "/"+strings.Repeat("a", 20)); w.Code != http.StatusRequestURITooLong {
+		t.Errorf("a path of 21 bytes: status %v, want 414", w.Code)
+	}
+}
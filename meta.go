@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// metaHTML returns the Open Graph and Twitter card tags describing the
+// page, taking the title, summary and image missing from its front matter
+// from the site variables of the same names.
+func metaHTML(cf *ContentFile) []byte {
+	title, description, image := cf.Title, cf.Summary, cf.Image
+	if title == "" {
+		title = siteString("title")
+	}
+	if description == "" {
+		description = siteString("description")
+	}
+	if image == "" {
+		image = siteString("image")
+	}
+	if strings.HasPrefix(image, "/") {
+		image = absoluteURL(image)
+	}
+
+	b := &bytes.Buffer{}
+	tag := func(attr, name, content string) {
+		if content != "" {
+			fmt.Fprintf(b, "<meta %v=\"%v\" content=\"%v\">\n", attr, name, html.EscapeString(content))
+		}
+	}
+
+	tag("property", "og:title", title)
+	tag("property", "og:description", description)
+	tag("property", "og:image", image)
+	if image != "" {
+		tag("name", "twitter:card", "summary_large_image")
+	} else {
+		tag("name", "twitter:card", "summary")
+	}
+
+	return b.Bytes()
+}
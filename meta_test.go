@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetaTags(t *testing.T) {
+	setFlag(t, "base-url", "https://example.com")
+	d := testSite(t, map[string]string{
+		"site.json": `{"title": "Site", "description": "About the site"}`,
+		"post.md":   "---\ntitle: Fish & Chips\nsummary: A recipe\nimage: /public/fish.jpg\n---\nPost",
+		"plain.md":  "Plain",
+	})
+
+	want := "<meta property=\"og:title\" content=\"Fish &amp; Chips\">\n" +
+		"<meta property=\"og:description\" content=\"A recipe\">\n" +
+		"<meta property=\"og:image\" content=\"https://example.com/public/fish.jpg\">\n" +
+		"<meta name=\"twitter:card\" content=\"summary_large_image\">\n"
+	if got := string(d.Files["post"].MetaHTML); got != want {
+		t.Errorf("meta tags %q, want %q", got, want)
+	}
+
+	got := string(d.Files["plain"].MetaHTML)
+	if !strings.Contains(got, `<meta property="og:title" content="Site">`) || !strings.Contains(got, `content="About the site"`) {
+		t.Errorf("meta tags %q do not fall back to the site variables", got)
+	}
+	if strings.Contains(got, "og:image") || !strings.Contains(got, `<meta name="twitter:card" content="summary">`) {
+		t.Errorf("meta tags %q for a page without an image", got)
+	}
+}
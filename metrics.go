@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var optMetricsAddr = flag.String("metrics-addr", "", "address of a separate listener serving Prometheus metrics at /metrics")
+var optMetricsRuntime = flag.Bool("metrics-runtime", false, "include Go runtime and process metrics")
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upublish_requests_total",
+		Help: "Requests served, by status code.",
+	}, []string{"code"})
+
+	renderDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "upublish_render_duration_seconds",
+		Help:    "Time taken to render and write pages.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	pageLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upublish_page_lookups_total",
+		Help: "Page lookups in the loaded tree, by whether the page was found.",
+	}, []string{"result"})
+
+	responseEncodings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upublish_response_encodings_total",
+		Help: "Rendered responses, by content coding.",
+	}, []string{"encoding"})
+)
+
+// setupMetrics returns the server for the metrics listener, or nil when
+// -metrics-addr is not set. Metrics are kept in their own registry so the
+// default one is not exposed.
+func setupMetrics() *http.Server {
+	if *optMetricsAddr == "" {
+		return nil
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(requestsTotal, renderDuration, pageLookups, responseEncodings)
+
+	if *optMetricsRuntime {
+		registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	return &http.Server{Addr: *optMetricsAddr, Handler: mux}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// scrape returns the value of the metric sample named, with its labels, as
+// in `upublish_page_lookups_total{result="hit"}`.
+func scrape(t *testing.T, h http.Handler, sample string) float64 {
+	t.Helper()
+
+	body := get(h, "/metrics").Body.String()
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, sample+" ") {
+			v, err := strconv.ParseFloat(strings.TrimPrefix(line, sample+" "), 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+func TestMetricsCountPageHits(t *testing.T) {
+	setFlag(t, "metrics-addr", "127.0.0.1:0")
+	testSite(t, map[string]string{"about.md": "About"})
+	h := testHandler()
+	metrics := setupMetrics().Handler
+
+	get(h, "/about")
+	hits := scrape(t, metrics, `upublish_page_lookups_total{result="hit"}`)
+	if hits < 1 {
+		t.Fatalf("%v hits after a request for a page", hits)
+	}
+
+	get(h, "/about")
+	if again := scrape(t, metrics, `upublish_page_lookups_total{result="hit"}`); again != hits+1 {
+		t.Errorf("%v hits after a second request, want %v", again, hits+1)
+	}
+
+	misses := scrape(t, metrics, `upublish_page_lookups_total{result="miss"}`)
+	get(h, "/missing")
+	if again := scrape(t, metrics, `upublish_page_lookups_total{result="miss"}`); again != misses+1 {
+		t.Errorf("%v misses after a request for a missing page, want %v", again, misses+1)
+	}
+}
+
+func TestNoMetricsListenerByDefault(t *testing.T) {
+	setFlag(t, "metrics-addr", "")
+	if srv := setupMetrics(); srv != nil {
+		t.Errorf("metrics listener at %v without -metrics-addr", srv.Addr)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+)
+
+var optMinify = flag.Bool("minify", false, "remove comments and collapse whitespace in the HTML of pages")
+
+// rawTags hold text which is copied as is, since whitespace matters in it
+// or it is not HTML.
+var rawTags = map[string]bool{"pre": true, "code": true, "textarea": true, "script": true, "style": true}
+
+// blockTags are those which whitespace between can be removed without
+// changing how the page is shown.
+var blockTags = map[string]bool{
+	"!doctype": true, "html": true, "head": true, "body": true, "title": true, "meta": true, "link": true,
+	"script": true, "style": true, "noscript": true, "div": true, "p": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "ul": true, "ol": true, "li": true, "dl": true,
+	"dt": true, "dd": true, "table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true,
+	"td": true, "th": true, "nav": true, "header": true, "footer": true, "main": true, "section": true,
+	"article": true, "aside": true, "figure": true, "figcaption": true, "blockquote": true, "pre": true,
+	"hr": true, "br": true, "form": true, "fieldset": true, "details": true, "summary": true,
+	"address": true, "hgroup": true, "caption": true, "colgroup": true, "col": true, "option": true,
+}
+
+var conditionalComment = []byte("<!--[if")
+
+// minifyHTML removes comments, other than conditional comments, collapses
+// runs of whitespace to a single space and removes whitespace between
+// block level tags. The contents of pre, code, textarea, script and style
+// elements are left alone.
+func minifyHTML(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	prevBlock := true
+
+	// for finding the ends of raw elements; lowered once, on the first
+	var lower []byte
+
+	for i := 0; i < len(b); {
+		if b[i] != '<' {
+			j := bytes.IndexByte(b[i:], '<')
+			if j < 0 {
+				j = len(b) - i
+			}
+
+			text := b[i : i+j]
+			i += j
+
+			if len(bytes.TrimSpace(text)) == 0 {
+				// comments are removed, so are as good as block level
+				nextBlock := i >= len(b) || blockTags[tagName(b[i:])] || bytes.HasPrefix(b[i:], []byte("<!--"))
+				if !prevBlock || !nextBlock {
+					out = append(out, ' ')
+				}
+				continue
+			}
+			out = appendCollapsed(out, text)
+			prevBlock = false
+			continue
+		}
+
+		if bytes.HasPrefix(b[i:], []byte("<!--")) {
+			end := bytes.Index(b[i+4:], []byte("-->"))
+			if end < 0 {
+				end = len(b) - i - 4
+			} else {
+				end += 3
+			}
+			if bytes.HasPrefix(b[i:], conditionalComment) {
+				out = append(out, b[i:i+4+end]...)
+			}
+			i += 4 + end
+			continue
+		}
+
+		end := tagEnd(b[i:])
+		name := tagName(b[i:])
+		out = append(out, b[i:i+end]...)
+		i += end
+		prevBlock = blockTags[name]
+
+		if rawTags[name] && b[i-end+1] != '/' {
+			if lower == nil {
+				lower = asciiLower(b)
+			}
+			closing := bytes.Index(lower[i:], []byte("</"+name))
+			if closing < 0 {
+				closing = len(b) - i
+			}
+			out = append(out, b[i:i+closing]...)
+			i += closing
+			prevBlock = false
+		}
+	}
+
+	return out
+}
+
+// tagName is the lower case name of the tag b starts with, without any /.
+func tagName(b []byte) string {
+	i := 1
+	if i < len(b) && b[i] == '/' {
+		i++
+	}
+
+	start := i
+	for i < len(b) && b[i] != '>' && b[i] != '/' && b[i] != ' ' && b[i] != '\t' && b[i] != '\n' && b[i] != '\r' {
+		i++
+	}
+	return strings.ToLower(string(b[start:i]))
+}
+
+// asciiLower is b with only the ASCII letters in lower case, so that
+// offsets into it are offsets into b.
+func asciiLower(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
+
+// tagEnd is the length of the tag b starts with, allowing for > in quoted
+// attribute values.
+func tagEnd(b []byte) int {
+	var quote byte
+	for i := 1; i < len(b); i++ {
+		switch {
+		case quote != 0:
+			if b[i] == quote {
+				quote = 0
+			}
+		case b[i] == '"' || b[i] == '\'':
+			quote = b[i]
+		case b[i] == '>':
+			return i + 1
+		}
+	}
+	return len(b)
+}
+
+func appendCollapsed(out, text []byte) []byte {
+	space := false
+	for _, c := range text {
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' {
+			space = true
+			continue
+		}
+		if space {
+			out = append(out, ' ')
+			space = false
+		}
+		out = append(out, c)
+	}
+	if space {
+		out = append(out, ' ')
+	}
+	return out
+}
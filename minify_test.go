@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMinifyCollapsesWhitespaceBetweenBlocks(t *testing.T) {
+	in := "<html>\n  <body>\n    <p>Some   <em>spaced</em>\n text</p>  <!-- note -->\n  <div> </div>\n  </body>\n</html>\n"
+	want := "<html><body><p>Some <em>spaced</em> text</p><div></div></body></html>"
+	if got := string(minifyHTML([]byte(in))); got != want {
+		t.Errorf("minifyHTML = %q, want %q", got, want)
+	}
+}
+
+func TestMinifyLeavesRawElements(t *testing.T) {
+	code := "<pre><code>func main() {\n\n    fmt.Println(\"a  b\")\n}\n</code></pre>"
+	in := "<div>\n" + code + "\n<SCRIPT>if (a  <  b) {}</SCRIPT>\n</div>"
+	got := string(minifyHTML([]byte(in)))
+
+	if !strings.Contains(got, code) {
+		t.Errorf("minifyHTML = %q, changed the code block %q", got, code)
+	}
+	if !strings.Contains(got, "<SCRIPT>if (a  <  b) {}</SCRIPT>") {
+		t.Errorf("minifyHTML = %q, changed the upper case script", got)
+	}
+}
+
+func TestMinifyKeepsConditionalComments(t *testing.T) {
+	in := "<head><!--[if IE]><link rel=\"stylesheet\" href=\"ie.css\"><![endif]--><!-- gone --></head>"
+	want := "<head><!--[if IE]><link rel=\"stylesheet\" href=\"ie.css\"><![endif]--></head>"
+	if got := string(minifyHTML([]byte(in))); got != want {
+		t.Errorf("minifyHTML = %q, want %q", got, want)
+	}
+}
+
+func TestMinifiedPagesServed(t *testing.T) {
+	setFlag(t, "minify", "true")
+	d := testSite(t, map[string]string{
+		"layout.html": "<html>\n  <body>\n    {{content}}\n  </body>\n</html>\n",
+		"about.md":    "About\n\n    indented  code\n",
+	})
+
+	cf := d.Files["about"]
+	if strings.Contains(string(cf.Rendered), "\n  <body>") {
+		t.Errorf("prerendered page %q is not minified", cf.Rendered)
+	}
+
+	w := get(http.HandlerFunc(renderPage), "/about")
+	if body := w.Body.String(); body != string(cf.Rendered) {
+		t.Errorf("body %q, want the prerendered %q", body, cf.Rendered)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "indented  code\n</code>") {
+		t.Errorf("body %q does not keep the code block", body)
+	}
+}
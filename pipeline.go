@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+)
+
+var optExt = flag.String("ext", "md", "comma separated extensions of the files served as pages, in order of precedence; md, markdown, html or txt")
+var optContentType = flag.String("content-type", "text/html; charset=UTF-8", "Content-Type header sent with rendered markdown pages")
+
+// pipeline describes how the files with an extension are served. Raw
+// files are served as they are, without front matter or a layout. Fragment
+// files are HTML put in the layout without markdown rendering.
+type pipeline struct {
+	contentType func() string
+	raw         bool
+	fragment    bool
+}
+
+var pipelines = map[string]pipeline{
+	".md":       {contentType: func() string { return *optContentType }},
+	".markdown": {contentType: func() string { return *optContentType }},
+	".html":     {contentType: func() string { return *optContentType }, fragment: true},
+	".txt":      {contentType: func() string { return "text/plain; charset=UTF-8" }, raw: true},
+}
+
+func validExtensions(exts string) bool {
+	for _, ext := range strings.Split(exts, ",") {
+		if _, ok := pipelines["."+strings.TrimSpace(ext)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// extRank is the position of the file name's extension in -ext; a page
+// takes precedence over others of the same name with a higher rank.
+func extRank(name string) int {
+	ext := filepath.Ext(name)
+	for i, e := range strings.Split(*optExt, ",") {
+		if "."+strings.TrimSpace(e) == ext {
+			return i
+		}
+	}
+	return -1
+}
+
+// pagePipeline returns the pipeline of the file name if its extension is
+// one of -ext. Layouts, those of views included, and index.html files,
+// which are served as they are, are never pages.
+func pagePipeline(name string) (pipeline, bool) {
+	if extRank(name) < 0 || name == LayoutFilename || isViewLayout(name) || name == "index.html" {
+		return pipeline{}, false
+	}
+	return pipelines[filepath.Ext(name)], true
+}
+
+// pageLayout returns the layout the page is rendered within.
+func pageLayout(d *Dir, cf *ContentFile) *LayoutFile {
+	if cf.Raw {
+		return nil
+	}
+	return d.Layout
+}
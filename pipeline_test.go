@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHTMLFragmentWrappedInLayout(t *testing.T) {
+	setFlag(t, "ext", "md,html")
+	testSite(t, map[string]string{
+		"contact.html": "---\ntitle: Contact\n---\n<p class=\"x\">Write *to* us</p>\n",
+	})
+
+	w := get(http.HandlerFunc(renderPage), "/contact")
+	if w.Code != 200 {
+		t.Fatalf("status %v, want 200", w.Code)
+	}
+
+	want := "<html><head><title>Contact</title></head><body><p class=\"x\">Write *to* us</p>\n</body></html>"
+	if body := w.Body.String(); body != want {
+		t.Errorf("body %q, want %q", body, want)
+	}
+	if w.Header().Get("Etag") == "" {
+		t.Error("no Etag")
+	}
+}
+
+func TestHTMLFragmentSanitized(t *testing.T) {
+	setFlag(t, "ext", "md,html")
+	testSite(t, map[string]string{
+		"frag.html": "<p onclick=\"steal()\">Hi</p><script>steal()</script>",
+	})
+
+	body := get(http.HandlerFunc(renderPage), "/frag").Body.String()
+	if strings.Contains(body, "<script>") || strings.Contains(body, "onclick") {
+		t.Errorf("body %q keeps the script", body)
+	}
+	if !strings.Contains(body, "<p>Hi</p>") {
+		t.Errorf("body %q lost the paragraph", body)
+	}
+
+	setFlag(t, "sanitize", "false")
+	loadSite(t)
+	if body := get(http.HandlerFunc(renderPage), "/frag").Body.String(); !strings.Contains(body, "<script>") {
+		t.Errorf("body %q is sanitized with -sanitize=false", body)
+	}
+}
+
+func TestLayoutsAreNotFragments(t *testing.T) {
+	setFlag(t, "ext", "md,html")
+	testSite(t, map[string]string{
+		"index.html":        "<html>static</html>",
+		"layout.print.html": "<div>{{content}}</div>",
+	})
+
+	for _, p := range []string{"/layout", "/layout.print", "/index"} {
+		if w := get(http.HandlerFunc(renderPage), p); w.Code != 404 {
+			t.Errorf("%v: status %v, want 404", p, w.Code)
+		}
+	}
+}
+
+func TestTextPagesServedRaw(t *testing.T) {
+	setFlag(t, "ext", "md,txt")
+	testSite(t, map[string]string{
+		"notes.txt": "Plain *text* <b>as written</b>\n",
+		"about.md":  "About",
+	})
+
+	w := get(http.HandlerFunc(renderPage), "/notes")
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	if body := w.Body.String(); body != "Plain *text* <b>as written</b>\n" {
+		t.Errorf("body %q, want the file as written", body)
+	}
+
+	if ct := get(http.HandlerFunc(renderPage), "/about").Header().Get("Content-Type"); ct != "text/html; charset=UTF-8" {
+		t.Errorf("markdown page Content-Type %q", ct)
+	}
+
+	setFlag(t, "ext", "md")
+	loadSite(t)
+	if w := get(http.HandlerFunc(renderPage), "/notes"); w.Code != 404 {
+		t.Errorf("/notes without txt in -ext: status %v, want 404", w.Code)
+	}
+}
+
+func TestValidExtensions(t *testing.T) {
+	for exts, want := range map[string]bool{"md": true, "md, markdown,html,txt": true, "md,rst": false, "": false} {
+		if got := validExtensions(exts); got != want {
+			t.Errorf("validExtensions(%q) = %v, want %v", exts, got, want)
+		}
+	}
+}
+
+func TestExtensionPrecedence(t *testing.T) {
+	files := map[string]string{
+		"about.md":       "From md",
+		"about.markdown": "From markdown",
+		"notes.markdown": "Only markdown",
+	}
+
+	setFlag(t, "ext", "md,markdown")
+	testSite(t, files)
+	if body := get(http.HandlerFunc(renderPage), "/about").Body.String(); !strings.Contains(body, "From md") {
+		t.Errorf("-ext=md,markdown: body %q", body)
+	}
+	if body := get(http.HandlerFunc(renderPage), "/notes").Body.String(); !strings.Contains(body, "Only markdown") {
+		t.Errorf("-ext=md,markdown: /notes body %q", body)
+	}
+
+	setFlag(t, "ext", "markdown,md")
+	loadSite(t)
+	if body := get(http.HandlerFunc(renderPage), "/about").Body.String(); !strings.Contains(body, "From markdown") {
+		t.Errorf("-ext=markdown,md: body %q", body)
+	}
+
+	setFlag(t, "ext", "md")
+	loadSite(t)
+	if w := get(http.HandlerFunc(renderPage), "/notes"); w.Code != 404 {
+		t.Errorf("-ext=md: /notes status %v, want 404", w.Code)
+	}
+}
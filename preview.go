@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var optPreviewSecret = flag.String("preview-secret", "", "secret signing ?preview= links, which show a draft page to anyone with the link")
+var optPreviewLink = flag.String("preview-link", "", "print a ?preview= link to the draft at this URL path, signed with -preview-secret, and exit")
+var optPreviewTTL = flag.Duration("preview-ttl", 0, "how long links from -preview-link are valid for; 0 for ever")
+
+// previewToken signs the URL path p, and the Unix time it expires at if
+// not zero, as "expiry.signature" or just "signature".
+func previewToken(p string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(*optPreviewSecret))
+	mac.Write([]byte(p + "\n" + strconv.FormatInt(expires, 10)))
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	if expires == 0 {
+		return sig
+	}
+	return strconv.FormatInt(expires, 10) + "." + sig
+}
+
+// validPreview reports whether the request's ?preview= token was signed
+// for its path and has not expired.
+func validPreview(r *http.Request) bool {
+	token := r.URL.Query().Get("preview")
+	if *optPreviewSecret == "" || token == "" {
+		return false
+	}
+
+	var expires int64
+	if i := strings.IndexByte(token, '.'); i >= 0 {
+		var err error
+		if expires, err = strconv.ParseInt(token[:i], 10, 64); err != nil || expires <= 0 {
+			return false
+		}
+		if time.Now().Unix() > expires {
+			return false
+		}
+	}
+
+	return hmac.Equal([]byte(token), []byte(previewToken(r.URL.Path, expires)))
+}
+
+func printPreviewLink() {
+	if *optPreviewSecret == "" {
+		log.Fatalf("-preview-link requires -preview-secret")
+	}
+
+	var expires int64
+	if *optPreviewTTL > 0 {
+		expires = time.Now().Add(*optPreviewTTL).Unix()
+	}
+
+	fmt.Println(*optPreviewLink + "?preview=" + url.QueryEscape(previewToken(*optPreviewLink, expires)))
+}
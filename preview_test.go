@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreviewLinks(t *testing.T) {
+	setFlag(t, "preview-secret", "s3cret")
+	testSite(t, map[string]string{"draft.md": "---\ndraft: true\n---\nNot yet", "other.md": "---\ndraft: true\n---\nOther"})
+	h := http.HandlerFunc(renderPage)
+
+	valid := previewToken("/draft", 0)
+	expiring := previewToken("/draft", time.Now().Add(time.Hour).Unix())
+	expired := previewToken("/draft", time.Now().Add(-time.Hour).Unix())
+	tampered := strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10) + expiring[strings.IndexByte(expiring, '.'):]
+
+	for _, token := range []string{valid, expiring} {
+		w := get(h, "/draft?preview="+token)
+		if w.Code != 200 || !strings.Contains(w.Body.String(), "Not yet") {
+			t.Fatalf("preview %v: status %v, body %q", token, w.Code, w.Body.String())
+		}
+		if cc, robots := w.Header().Get("Cache-Control"), w.Header().Get("X-Robots-Tag"); cc != "private, no-store" || robots != "noindex" {
+			t.Errorf("preview Cache-Control %q, X-Robots-Tag %q", cc, robots)
+		}
+	}
+
+	for _, u := range []string{"/draft", "/draft?preview=" + expired, "/draft?preview=" + tampered, "/draft?preview=nonsense", "/other?preview=" + valid} {
+		if w := get(h, u); w.Code != 404 {
+			t.Errorf("%v: status %v, want 404", u, w.Code)
+		}
+	}
+
+	setFlag(t, "preview-secret", "")
+	if w := get(h, "/draft?preview="+valid); w.Code != 404 {
+		t.Errorf("preview without -preview-secret: status %v, want 404", w.Code)
+	}
+}
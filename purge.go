@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+type purgeRequest struct {
+	Path string `json:"path"`
+	All  bool   `json:"all"`
+}
+
+// setupPurge serves POST /admin/purge, which reloads the pages as USR1
+// does, when -auth-user is set. The pages are loaded together, as the
+// search index, sitemap and related links are built from all of them, so
+// purging a single path reloads them all as well; it only checks the path
+// names a page.
+func setupPurge(mux *http.ServeMux) {
+	if *optAuthUser == "" {
+		return
+	}
+
+	mux.HandleFunc("/admin/purge", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writePurge(w, http.StatusMethodNotAllowed, 0)
+			return
+		}
+
+		var req purgeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil || (req.Path == "" && !req.All) {
+			writePurge(w, http.StatusBadRequest, 0)
+			return
+		}
+
+		if req.All {
+			log.Println("Purging all pages")
+		} else if _, cf := findPage(getTree(), req.Path); cf != nil {
+			log.Printf("Purging %v\n", req.Path)
+		} else {
+			writePurge(w, http.StatusNotFound, 0)
+			return
+		}
+
+		if !reload() {
+			writePurge(w, http.StatusInternalServerError, 0)
+			return
+		}
+
+		reloaded := 0
+		getTree().Walk(func(p string, d *Dir) {
+			reloaded += len(d.Files)
+		})
+		writePurge(w, http.StatusOK, reloaded)
+	})
+}
+
+// writePurge answers with the number of pages reloaded, which is all of
+// them whichever were purged.
+func writePurge(w http.ResponseWriter, statusCode int, reloaded int) {
+	b, _ := json.Marshal(map[string]int{"reloaded": reloaded})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	w.Write(b)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// purge POSTs body to /admin/purge through h, as user with pass.
+func purge(h http.Handler, user, pass, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/admin/purge", strings.NewReader(body))
+	r.SetBasicAuth(user, pass)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func testPurgeSite(t *testing.T) http.Handler {
+	setFlag(t, "auth-user", "admin")
+	setFlag(t, "auth-pass", "secret")
+	testSite(t, map[string]string{
+		"index.md":         "Home",
+		"about.md":         "About",
+		"articles/abc.md":  "ABC",
+		"articles/next.md": "Next",
+	})
+	return testHandler()
+}
+
+func TestPurgePath(t *testing.T) {
+	h := testPurgeSite(t)
+	before := getTree()
+
+	w := purge(h, "admin", "secret", `{"path": "/articles/abc"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %v, want 200", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"reloaded":4}` {
+		t.Errorf("body %v, want every page reloaded", body)
+	}
+	if getTree() == before {
+		t.Error("the pages were not reloaded")
+	}
+
+	if w := purge(h, "admin", "secret", `{"path": "/articles/missing"}`); w.Code != http.StatusNotFound {
+		t.Errorf("purging a missing page: status %v, want 404", w.Code)
+	}
+}
+
+func TestPurgeAll(t *testing.T) {
+	h := testPurgeSite(t)
+
+	w := purge(h, "admin", "secret", `{"all": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %v, want 200", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"reloaded":4}` {
+		t.Errorf("body %v, want every page reloaded", body)
+	}
+
+	if w := purge(h, "admin", "secret", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("purging nothing: status %v, want 400", w.Code)
+	}
+}
+
+func TestPurgeUnauthorized(t *testing.T) {
+	h := testPurgeSite(t)
+	before := getTree()
+
+	for _, pass := range []string{"", "wrong"} {
+		w := purge(h, "admin", pass, `{"all": true}`)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("password %q: status %v, want 401", pass, w.Code)
+		}
+	}
+	if getTree() != before {
+		t.Error("the pages were reloaded without authorization")
+	}
+}
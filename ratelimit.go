@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var optRateLimit = flag.Float64("rate-limit", 0, "requests per second allowed from each client IP; 0 for no limit")
+var optRateBurst = flag.Int("rate-burst", 20, "requests a client IP may make at once before -rate-limit applies")
+var optTrustProxy = flag.Bool("trust-proxy", false, "take the client IP from the X-Forwarded-For header added by a proxy")
+
+var rateIdle = 3 * time.Minute
+
+type clientLimiter struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+type rateLimiter struct {
+	sync.Mutex
+	clients map[string]*clientLimiter
+}
+
+func rateLimit(h http.Handler) http.Handler {
+	if *optRateLimit <= 0 {
+		return h
+	}
+
+	rl := &rateLimiter{clients: make(map[string]*clientLimiter)}
+	go rl.evict()
+
+	retry := strconv.Itoa(int(math.Max(1, math.Ceil(1 / *optRateLimit))))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rl.allow(clientIP(r)) {
+			w.Header().Set("Retry-After", retry)
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (rl *rateLimiter) allow(ip string) bool {
+	rl.Lock()
+	defer rl.Unlock()
+
+	c, ok := rl.clients[ip]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(*optRateLimit), *optRateBurst)}
+		rl.clients[ip] = c
+	}
+
+	c.seen = time.Now()
+	return c.limiter.Allow()
+}
+
+// evict forgets clients that have not made a request for a while, keeping
+// the number of buckets bounded.
+func (rl *rateLimiter) evict() {
+	for {
+		time.Sleep(rateIdle)
+
+		rl.Lock()
+		for ip, c := range rl.clients {
+			if time.Since(c.seen) > rateIdle {
+				delete(rl.clients, ip)
+			}
+		}
+		rl.Unlock()
+	}
+}
+
+// clientIP is the address of the client making the request. Behind a
+// trusted proxy this is the last X-Forwarded-For entry, the one the proxy
+// added itself.
+func clientIP(r *http.Request) string {
+	if *optTrustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			ips := strings.Split(xff, ",")
+			return strings.TrimSpace(ips[len(ips)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitPerClient(t *testing.T) {
+	setFlag(t, "rate-limit", "0.5")
+	setFlag(t, "rate-burst", "3")
+	testSite(t, map[string]string{"about.md": "About"})
+	h := testHandler()
+
+	for i := 0; i < 3; i++ {
+		if w := get(h, "/about"); w.Code != http.StatusOK {
+			t.Fatalf("request %v within the burst: status %v", i+1, w.Code)
+		}
+	}
+	w := get(h, "/about")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request after the burst: status %v, want 429", w.Code)
+	}
+	if ra := w.Header().Get("Retry-After"); ra != "2" {
+		t.Errorf("Retry-After %q, want 2", ra)
+	}
+
+	// another client has its own bucket
+	r := httptest.NewRequest("GET", "/about", nil)
+	r.RemoteAddr = "198.51.100.7:4321"
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("request from another client: status %v, want 200", w.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.5:1234"
+	r.Header.Set("X-Forwarded-For", "10.0.0.1, 192.0.2.9")
+
+	if ip := clientIP(r); ip != "203.0.113.5" {
+		t.Errorf("clientIP = %q, want the remote address", ip)
+	}
+
+	setFlag(t, "trust-proxy", "true")
+	if ip := clientIP(r); ip != "192.0.2.9" {
+		t.Errorf("clientIP behind a proxy = %q, want the last X-Forwarded-For entry", ip)
+	}
+}
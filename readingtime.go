@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var optReadingWPM = flag.Int("reading-wpm", 200, "words read per minute, for {{readingtime}}")
+var optReadingCode = flag.Bool("reading-code", true, "count the words in code blocks towards {{readingtime}}")
+
+var prePattern = regexp.MustCompile(`(?s)<pre\b.*?</pre>`)
+
+// readingTime estimates the minutes taken to read the rendered content,
+// rounded up and at least one.
+func readingTime(content []byte) int {
+	if !*optReadingCode {
+		content = prePattern.ReplaceAll(content, nil)
+	}
+
+	text := html.UnescapeString(string(tagPattern.ReplaceAll(content, []byte(" "))))
+	words := len(strings.Fields(text))
+
+	wpm := *optReadingWPM
+	if wpm < 1 {
+		wpm = 200
+	}
+
+	if minutes := (words + wpm - 1) / wpm; minutes > 1 {
+		return minutes
+	}
+	return 1
+}
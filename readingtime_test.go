@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadingTime(t *testing.T) {
+	words := func(n int) string { return strings.TrimSpace(strings.Repeat("word ", n)) }
+	code := "<pre><code>" + words(300) + "</code></pre>"
+
+	for _, test := range []struct {
+		content string
+		want    int
+	}{
+		{"", 1},
+		{"<p>" + words(10) + "</p>", 1},
+		{"<p>" + words(200) + "</p>", 1},
+		{"<p>" + words(201) + "</p>", 2},
+		{"<p>" + words(100) + "</p>" + code, 2},
+	} {
+		if got := readingTime([]byte(test.content)); got != test.want {
+			t.Errorf("readingTime of %v bytes = %v, want %v", len(test.content), got, test.want)
+		}
+	}
+
+	setFlag(t, "reading-code", "false")
+	if got := readingTime([]byte("<p>" + words(100) + "</p>" + code)); got != 1 {
+		t.Errorf("readingTime without code = %v, want 1", got)
+	}
+
+	setFlag(t, "reading-wpm", "50")
+	if got := readingTime([]byte("<p>" + words(120) + "</p>")); got != 3 {
+		t.Errorf("readingTime at 50 words a minute = %v, want 3", got)
+	}
+}
+
+func TestReadingTimePlaceholder(t *testing.T) {
+	testSite(t, map[string]string{
+		"layout.html": "{{readingtime}}: {{content}}",
+		"about.md":    strings.Repeat("word ", 450),
+	})
+
+	if body := get(http.HandlerFunc(renderPage), "/about").Body.String(); !strings.HasPrefix(body, "3 min read: ") {
+		t.Errorf("body %q", body)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var RedirectsFilename = "redirects"
+
+// Redirect sends requests for From to To. A From ending in /* matches
+// everything below it, and a To ending in * receives the matched rest.
+type Redirect struct {
+	From, To string
+	Code     int
+}
+
+type Redirects struct {
+	exact    map[string]Redirect
+	prefixed []Redirect
+}
+
+// readRedirects parses the redirects file in dir, one "from to [code]"
+// per line. A missing file means there are no redirects.
+func readRedirects(dir string) (*Redirects, error) {
+	rs := &Redirects{exact: make(map[string]Redirect)}
+
+	b, err := readFile(filepath.Join(dir, RedirectsFilename))
+	if os.IsNotExist(err) {
+		return rs, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %v: expected 'from to [code]'", n)
+		}
+
+		r := Redirect{From: fields[0], To: fields[1], Code: 301}
+		if len(fields) == 3 {
+			if r.Code, err = strconv.Atoi(fields[2]); err != nil || r.Code < 300 || r.Code > 308 {
+				return nil, fmt.Errorf("line %v: invalid redirect status '%v'", n, fields[2])
+			}
+		}
+
+		if strings.HasSuffix(r.From, "/*") {
+			r.From = r.From[:len(r.From)-1]
+			rs.prefixed = append(rs.prefixed, r)
+		} else {
+			rs.exact[trimSlash(r.From)] = r
+		}
+	}
+
+	return rs, nil
+}
+
+func trimSlash(p string) string {
+	if len(p) > 1 {
+		return strings.TrimRight(p, "/")
+	}
+	return p
+}
+
+// Find returns the target and status code of the redirect for the URL
+// path p, ignoring any trailing slash.
+func (rs *Redirects) Find(p string) (string, int, bool) {
+	if rs == nil {
+		return "", 0, false
+	}
+
+	if r, ok := rs.exact[trimSlash(p)]; ok {
+		return r.To, r.Code, true
+	}
+
+	for _, r := range rs.prefixed {
+		if !strings.HasPrefix(p, r.From) && trimSlash(p) != trimSlash(r.From) {
+			continue
+		}
+
+		if !strings.HasSuffix(r.To, "*") {
+			return r.To, r.Code, true
+		}
+
+		rest := ""
+		if len(p) > len(r.From) {
+			rest = p[len(r.From):]
+		}
+		return r.To[:len(r.To)-1] + rest, r.Code, true
+	}
+
+	return "", 0, false
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRedirects(t *testing.T) {
+	testSite(t, map[string]string{
+		"about.md":  "About",
+		"redirects": "# moved pages\n/old /about\n/temp /about 302\n/blog/* /posts/*\n/docs/* /manual\n",
+	})
+
+	for u, want := range map[string]struct {
+		code     int
+		location string
+	}{
+		"/old":             {301, "/about"},
+		"/old/":            {301, "/about"},
+		"/temp":            {302, "/about"},
+		"/blog/2023/post":  {301, "/posts/2023/post"},
+		"/blog/":           {301, "/posts/"},
+		"/docs/setup/tips": {301, "/manual"},
+	} {
+		w := get(http.HandlerFunc(renderPage), u)
+		if w.Code != want.code || w.Header().Get("Location") != want.location {
+			t.Errorf("%v: %v to %q, want %v to %q", u, w.Code, w.Header().Get("Location"), want.code, want.location)
+		}
+	}
+
+	if w := get(http.HandlerFunc(renderPage), "/about"); w.Code != http.StatusOK {
+		t.Errorf("/about: status %v, want 200", w.Code)
+	}
+}
+
+func TestRedirectsFileErrors(t *testing.T) {
+	for _, redirects := range []string{"/old\n", "/old /new 301 extra\n", "/old /new 200\n", "/old /new moved\n"} {
+		writeSite(t, map[string]string{"layout.html": testLayout, "about.md": "About", "redirects": redirects})
+		if _, err := readRedirects(root); err == nil {
+			t.Errorf("reading redirects %q succeeded", redirects)
+		}
+	}
+}
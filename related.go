@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+var optRelated = flag.Int("related", 5, "number of pages listed by {{related}}")
+
+type relatedPage struct {
+	path string
+	cf   *ContentFile
+}
+
+// setRelated fills in the {{related}} list of each page: the pages sharing
+// the most tags with it, those dated closest to it first among equals.
+// With a -related of 0 or less, every list is left empty.
+func setRelated(d *Dir) {
+	if *optRelated < 1 {
+		return
+	}
+
+	var pages []relatedPage
+	byTag := make(map[string][]int)
+
+	d.Walk(func(p string, dir *Dir) {
+		for _, n := range dir.FileNames() {
+			cf := dir.Files[n]
+			if (p == "/" && n == *optNotFound) || (cf.Draft && !*optShowDrafts) {
+				continue
+			}
+
+			for _, tag := range cf.Tags {
+				byTag[tag] = append(byTag[tag], len(pages))
+			}
+			pages = append(pages, relatedPage{linkURL(dir, p, n), cf})
+		}
+	})
+
+	for id, page := range pages {
+		shared := make(map[int]int)
+		for _, tag := range page.cf.Tags {
+			for _, other := range byTag[tag] {
+				// protected pages are only listed on other protected pages
+				if other != id && (!protected(pages[other].path) || protected(page.path)) {
+					shared[other]++
+				}
+			}
+		}
+
+		if len(shared) == 0 {
+			continue
+		}
+
+		ids := make([]int, 0, len(shared))
+		for other := range shared {
+			ids = append(ids, other)
+		}
+
+		sort.Slice(ids, func(i, j int) bool {
+			a, b := ids[i], ids[j]
+			if shared[a] != shared[b] {
+				return shared[a] > shared[b]
+			}
+			da, db := dateDistance(page.cf, pages[a].cf), dateDistance(page.cf, pages[b].cf)
+			if da != db {
+				return da < db
+			}
+			return pages[a].path < pages[b].path
+		})
+
+		if len(ids) > *optRelated {
+			ids = ids[:*optRelated]
+		}
+
+		b := &strings.Builder{}
+		b.WriteString("<ul class=\"related\">\n")
+		for _, other := range ids {
+			title := pages[other].cf.Title
+			if title == "" {
+				title = pages[other].path
+			}
+			b.WriteString("<li><a href=\"" + html.EscapeString(pages[other].path) + "\">" + html.EscapeString(title) + "</a></li>\n")
+		}
+		b.WriteString("</ul>\n")
+
+		page.cf.RelatedHTML = []byte(b.String())
+		// the rendered page changes with its related pages
+		page.cf.Hash = pageHash(page.cf)
+	}
+}
+
+func dateDistance(a, b *ContentFile) time.Duration {
+	d := a.Date.Sub(b.Date.Time)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRelatedRanksMoreSharedTagsFirst(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"a.md":   "---\ntags: [go, web]\n---\nA",
+		"two.md": "---\ntags: [go, web]\n---\nTwo",
+		"one.md": "---\ntags: [go]\n---\nOne",
+		"no.md":  "---\ntags: [rust]\n---\nNo",
+	})
+
+	related := string(d.Files["a"].RelatedHTML)
+	two, one := strings.Index(related, `href="/two"`), strings.Index(related, `href="/one"`)
+	if two < 0 || one < 0 || two > one {
+		t.Errorf("related %q does not list /two before /one", related)
+	}
+	if strings.Contains(related, "/no") {
+		t.Errorf("related %q lists a page without shared tags", related)
+	}
+}
+
+func TestRelatedLeavesOutProtectedPages(t *testing.T) {
+	setFlag(t, "auth-prefix", "/blog/")
+	setFlag(t, "auth-user", "user")
+	setFlag(t, "auth-pass", "pass")
+	d := testSite(t, map[string]string{
+		"index.md":      "---\ntags: [go]\n---\nHome",
+		"about.md":      "---\ntags: [go]\n---\nAbout",
+		"blog/post1.md": "---\ntags: [go]\n---\nPost",
+		"blog/post2.md": "---\ntags: [go]\n---\nPost",
+	})
+
+	for _, n := range []string{"index", "about"} {
+		if related := string(d.Files[n].RelatedHTML); strings.Contains(related, "/blog/") {
+			t.Errorf("related of %v %q lists a protected page", n, related)
+		}
+	}
+
+	if related := string(d.Directories["blog"].Files["post1"].RelatedHTML); !strings.Contains(related, "/blog/post2") || !strings.Contains(related, `href="/about"`) {
+		t.Errorf("related of a protected page %q does not list both kinds of page", related)
+	}
+}
+
+func TestRelatedDisabled(t *testing.T) {
+	for _, n := range []string{"0", "-1"} {
+		setFlag(t, "related", n)
+		d := testSite(t, map[string]string{
+			"a.md": "---\ntags: [go]\n---\nA",
+			"b.md": "---\ntags: [go]\n---\nB",
+		})
+
+		if related := d.Files["a"].RelatedHTML; related != nil {
+			t.Errorf("with -related=%v related %q, want none", n, related)
+		}
+	}
+}
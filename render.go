@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	md "github.com/russross/blackfriday"
+)
+
+var optMdTables = flag.Bool("md-tables", true, "render markdown tables")
+var optMdFootnotes = flag.Bool("md-footnotes", false, "render markdown footnotes")
+var optMdTaskLists = flag.Bool("md-tasklists", false, "render list items starting with [ ] or [x] as checkboxes")
+var optMdSmartypants = flag.Bool("md-smartypants", true, "render smart quotes, dashes and fractions")
+var optHeadingAnchors = flag.Bool("heading-anchors", false, "give every heading an id and a # link to it")
+
+// The flags and extensions used by blackfriday's MarkdownCommon, less
+// those chosen on the command line.
+const (
+	htmlFlags = md.HTML_USE_XHTML
+
+	smartypantsFlags = md.HTML_USE_SMARTYPANTS |
+		md.HTML_SMARTYPANTS_FRACTIONS |
+		md.HTML_SMARTYPANTS_DASHES |
+		md.HTML_SMARTYPANTS_LATEX_DASHES
+
+	extensions = md.EXTENSION_NO_INTRA_EMPHASIS |
+		md.EXTENSION_FENCED_CODE |
+		md.EXTENSION_AUTOLINK |
+		md.EXTENSION_STRIKETHROUGH |
+		md.EXTENSION_SPACE_HEADERS |
+		md.EXTENSION_HEADER_IDS |
+		md.EXTENSION_BACKSLASH_LINE_BREAK |
+		md.EXTENSION_DEFINITION_LISTS
+)
+
+func markdownOptions() (int, int) {
+	flags, exts := htmlFlags, extensions
+
+	if *optMdSmartypants {
+		flags |= smartypantsFlags
+	}
+	if *optMdTables {
+		exts |= md.EXTENSION_TABLES
+	}
+	if *optMdFootnotes {
+		exts |= md.EXTENSION_FOOTNOTES
+	}
+
+	return flags, exts
+}
+
+// Every token type is given a class, so the generated HTML is the same
+// whichever style the CSS is produced from.
+var highlighter = chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithAllClasses(true))
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Heading is an entry in a page's table of contents.
+type Heading struct {
+	Level int
+	ID    string
+	Title string
+}
+
+// htmlRenderer is blackfriday's HTML renderer with fenced code blocks
+// highlighted when their language is known, h2/h3 headings given unique
+// ids and collected into a table of contents, and optional task lists.
+type htmlRenderer struct {
+	md.Renderer
+
+	ids map[string]bool
+	toc []Heading
+}
+
+func newHTMLRenderer(flags int) *htmlRenderer {
+	return &htmlRenderer{Renderer: md.HtmlRenderer(flags, "", ""), ids: make(map[string]bool)}
+}
+
+var taskPattern = regexp.MustCompile(`^(<p>)?\[([ xX])\] `)
+
+// ListItem renders items starting with "[ ]" or "[x]" as task list items
+// when -md-tasklists is set.
+func (r *htmlRenderer) ListItem(out *bytes.Buffer, text []byte, flags int) {
+	if *optMdTaskLists {
+		if m := taskPattern.FindSubmatch(text); m != nil {
+			box := `<input type="checkbox" disabled="disabled" /> `
+			if m[2][0] != ' ' {
+				box = `<input type="checkbox" checked="checked" disabled="disabled" /> `
+			}
+			text = append([]byte(string(m[1])+box), text[len(m[0]):]...)
+		}
+	}
+
+	r.Renderer.ListItem(out, text, flags)
+}
+
+func (r *htmlRenderer) Header(out *bytes.Buffer, text func() bool, level int, id string) {
+	marker := out.Len()
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+
+	inner := append([]byte(nil), out.Bytes()[marker:]...)
+	out.Truncate(marker)
+
+	title := html.UnescapeString(string(tagPattern.ReplaceAll(inner, nil)))
+
+	if id == "" && (level == 2 || level == 3 || *optHeadingAnchors) {
+		id = slug(title)
+	}
+	if id != "" {
+		id = r.uniqueID(id)
+	}
+
+	if out.Len() > 0 {
+		out.WriteByte('\n')
+	}
+
+	if id != "" {
+		fmt.Fprintf(out, "<h%d id=\"%s\">", level, id)
+	} else {
+		fmt.Fprintf(out, "<h%d>", level)
+	}
+	out.Write(inner)
+	if id != "" && *optHeadingAnchors {
+		fmt.Fprintf(out, " <a class=\"anchor\" href=\"#%s\">#</a>", id)
+	}
+	fmt.Fprintf(out, "</h%d>\n", level)
+
+	if level == 2 || level == 3 {
+		r.toc = append(r.toc, Heading{level, id, title})
+	}
+}
+
+func (r *htmlRenderer) uniqueID(id string) string {
+	unique := id
+	for i := 2; r.ids[unique]; i++ {
+		unique = id + "-" + strconv.Itoa(i)
+	}
+	r.ids[unique] = true
+	return unique
+}
+
+func slug(title string) string {
+	s := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if s == "" {
+		return "section"
+	}
+	return s
+}
+
+// tocHTML renders headings as a list of links, with each h3 nested under
+// the h2 before it.
+func tocHTML(toc []Heading) []byte {
+	if len(toc) == 0 {
+		return nil
+	}
+
+	b := &bytes.Buffer{}
+	b.WriteString("<ul class=\"toc\">\n")
+
+	open, nested := false, false
+	for _, h := range toc {
+		link := fmt.Sprintf("<a href=\"#%s\">%s</a>", h.ID, html.EscapeString(h.Title))
+
+		if h.Level == 3 && open {
+			if !nested {
+				b.WriteString("<ul>\n")
+				nested = true
+			}
+			b.WriteString("<li>" + link + "</li>\n")
+			continue
+		}
+
+		if nested {
+			b.WriteString("</ul>\n")
+			nested = false
+		}
+		if open {
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("<li>" + link + "\n")
+		open = true
+	}
+
+	if nested {
+		b.WriteString("</ul>\n")
+	}
+	if open {
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n")
+
+	return b.Bytes()
+}
+
+func (r *htmlRenderer) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	lang := info
+	if i := strings.IndexAny(info, "\t "); i >= 0 {
+		lang = info[:i]
+	}
+
+	lexer := lexers.Get(lang)
+	if lang == "" || lexer == nil {
+		r.Renderer.BlockCode(out, text, info)
+		return
+	}
+
+	it, err := lexer.Tokenise(nil, string(text))
+	if err != nil {
+		r.Renderer.BlockCode(out, text, info)
+		return
+	}
+
+	if out.Len() > 0 {
+		out.WriteByte('\n')
+	}
+	highlighter.Format(out, styles.Fallback, it)
+	out.WriteByte('\n')
+}
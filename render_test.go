@@ -0,0 +1,119 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSlug(t *testing.T) {
+	for title, want := range map[string]string{
+		"Getting Started":     "getting-started",
+		"  What's new?  ":     "what-s-new",
+		"C++ & Go, 2023 Ed.":  "c-go-2023-ed",
+		"***":                 "section",
+		"Ünïcode":             "n-code",
+		"already-a-slug_too ": "already-a-slug-too",
+	} {
+		if got := slug(title); got != want {
+			t.Errorf("slug(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"layout.html": "{{toc}}{{content}}",
+		"page.md":     "# Title\n\n## Install\n\n### Linux\n\n### Mac\n\n## Use\n\n## Install\n",
+	})
+
+	cf := d.Files["page"]
+	want := []Heading{{2, "install", "Install"}, {3, "linux", "Linux"}, {3, "mac", "Mac"}, {2, "use", "Use"}, {2, "install-2", "Install"}}
+	if !reflect.DeepEqual(cf.TOC, want) {
+		t.Errorf("TOC %v, want %v", cf.TOC, want)
+	}
+
+	toc := "<ul class=\"toc\">\n" +
+		"<li><a href=\"#install\">Install</a>\n<ul>\n<li><a href=\"#linux\">Linux</a></li>\n<li><a href=\"#mac\">Mac</a></li>\n</ul>\n</li>\n" +
+		"<li><a href=\"#use\">Use</a>\n</li>\n" +
+		"<li><a href=\"#install-2\">Install</a>\n</li>\n" +
+		"</ul>\n"
+	if string(cf.TOCHTML) != toc {
+		t.Errorf("TOC HTML %q, want %q", cf.TOCHTML, toc)
+	}
+
+	for _, id := range []string{`<h2 id="install">`, `<h3 id="linux">`, `<h2 id="install-2">`} {
+		if !strings.Contains(string(cf.Content), id) {
+			t.Errorf("content %q lacks %v", cf.Content, id)
+		}
+	}
+}
+
+// renderMarkdown renders in with the -renderer.
+func renderMarkdown(t *testing.T, in string) string {
+	t.Helper()
+
+	out, err := newRenderer().Render([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestMarkdownExtensions(t *testing.T) {
+	const table = "a | b\n---|---\n1 | 2\n"
+	const footnote = "Text[^1]\n\n[^1]: The note.\n"
+	const tasks = "- [ ] todo\n- [x] done\n"
+	const quotes = "\"quoted\" -- dash\n"
+
+	if out := renderMarkdown(t, table); !strings.Contains(out, "<table>") {
+		t.Errorf("table rendered as %q", out)
+	}
+	if out := renderMarkdown(t, footnote); strings.Contains(out, "footnote") {
+		t.Errorf("footnote rendered by default as %q", out)
+	}
+	if out := renderMarkdown(t, tasks); strings.Contains(out, "checkbox") {
+		t.Errorf("task list rendered by default as %q", out)
+	}
+	if out := renderMarkdown(t, quotes); !strings.Contains(out, "“quoted”") || !strings.Contains(out, "–") {
+		t.Errorf("smart quotes rendered as %q", out)
+	}
+
+	setFlag(t, "md-tables", "false")
+	setFlag(t, "md-footnotes", "true")
+	setFlag(t, "md-tasklists", "true")
+	setFlag(t, "md-smartypants", "false")
+
+	if out := renderMarkdown(t, table); strings.Contains(out, "<table>") {
+		t.Errorf("table rendered with -md-tables=false as %q", out)
+	}
+	if out := renderMarkdown(t, footnote); !strings.Contains(out, `class="footnotes"`) {
+		t.Errorf("footnote rendered with -md-footnotes as %q", out)
+	}
+	if out := renderMarkdown(t, tasks); !strings.Contains(out, `<input type="checkbox" disabled="disabled"/> todo`) || !strings.Contains(out, `checked="checked"`) {
+		t.Errorf("task list rendered with -md-tasklists as %q", out)
+	}
+	if out := renderMarkdown(t, quotes); strings.Contains(out, "“") {
+		t.Errorf("quotes rendered with -md-smartypants=false as %q", out)
+	}
+}
+
+func TestHeadingAnchors(t *testing.T) {
+	const page = "# Title\n\n## Install\n\n#### Details {#more}\n"
+
+	if out := renderMarkdown(t, page); strings.Contains(out, `class="anchor"`) || strings.Contains(out, `<h1 id=`) {
+		t.Errorf("headings rendered without -heading-anchors as %q", out)
+	}
+
+	setFlag(t, "heading-anchors", "true")
+	out := renderMarkdown(t, page)
+	for _, h := range []string{
+		`<h1 id="title">Title <a class="anchor" href="#title">#</a></h1>`,
+		`<h2 id="install">Install <a class="anchor" href="#install">#</a></h2>`,
+		`<h4 id="more">Details <a class="anchor" href="#more">#</a></h4>`,
+	} {
+		if !strings.Contains(out, h) {
+			t.Errorf("headings rendered as %q, want %q", out, h)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+
+	md "github.com/russross/blackfriday"
+)
+
+var optRenderer = flag.String("renderer", "blackfriday", "markdown renderer; 'blackfriday' or 'basic' for markdown without extensions")
+
+// Renderer turns the markdown of a page into HTML. A new Renderer is used
+// for each page.
+type Renderer interface {
+	Render(input []byte) ([]byte, error)
+}
+
+// tocRenderer is a Renderer which collects the page's table of contents
+// as it renders.
+type tocRenderer interface {
+	Renderer
+	TOC() []Heading
+}
+
+var renderers = map[string]func() Renderer{
+	"blackfriday": func() Renderer {
+		flags, exts := markdownOptions()
+		return &markdownRenderer{newHTMLRenderer(flags), exts}
+	},
+	"basic": func() Renderer {
+		return &markdownRenderer{newHTMLRenderer(htmlFlags), 0}
+	},
+}
+
+func validRenderer(name string) bool {
+	_, ok := renderers[name]
+	return ok
+}
+
+// newRenderer returns the -renderer, with its output sanitized and its
+// images and links to other sites prepared.
+func newRenderer() Renderer {
+	return postProcessed(renderers[*optRenderer]())
+}
+
+// newFragmentRenderer returns a Renderer for HTML fragments, which are not
+// markdown but are sanitized and prepared as rendered pages are.
+func newFragmentRenderer() Renderer {
+	return postProcessed(htmlFragment{})
+}
+
+func postProcessed(next Renderer) Renderer {
+	r := postProcess{next, sanitize}
+	r = postProcess{r, prepareImages}
+	return postProcess{r, prepareExternalLinks}
+}
+
+// htmlFragment is a Renderer for input which is HTML already.
+type htmlFragment struct{}
+
+func (htmlFragment) Render(input []byte) ([]byte, error) {
+	return input, nil
+}
+
+type markdownRenderer struct {
+	r          *htmlRenderer
+	extensions int
+}
+
+func (m *markdownRenderer) Render(input []byte) ([]byte, error) {
+	return md.Markdown(input, m.r, m.extensions), nil
+}
+
+func (m *markdownRenderer) TOC() []Heading {
+	return m.r.toc
+}
+
+// postProcess is a Renderer passing the output of another through fn.
+type postProcess struct {
+	next Renderer
+	fn   func([]byte) []byte
+}
+
+func (p postProcess) Render(input []byte) ([]byte, error) {
+	out, err := p.next.Render(input)
+	if err != nil {
+		return nil, err
+	}
+	return p.fn(out), nil
+}
+
+func (p postProcess) TOC() []Heading {
+	if t, ok := p.next.(tocRenderer); ok {
+		return t.TOC()
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// shoutRenderer upper cases the page, standing in for another markdown
+// renderer.
+type shoutRenderer struct{}
+
+func (shoutRenderer) Render(input []byte) ([]byte, error) {
+	return bytes.ToUpper(input), nil
+}
+
+func TestCustomRenderer(t *testing.T) {
+	renderers["shout"] = func() Renderer { return shoutRenderer{} }
+	defer delete(renderers, "shout")
+	setFlag(t, "renderer", "shout")
+
+	d := testSite(t, map[string]string{"about.md": "<p>quiet <script>x()</script></p>\n\n## Heading\n"})
+
+	cf := d.Files["about"]
+	if c := string(cf.Content); !strings.Contains(c, "<p>QUIET </p>") || strings.Contains(c, "SCRIPT") {
+		t.Errorf("content %q, want it rendered by the renderer and sanitized", c)
+	}
+	if cf.TOC != nil {
+		t.Errorf("TOC %v from a renderer which does not collect one", cf.TOC)
+	}
+	if body := get(http.HandlerFunc(renderPage), "/about").Body.String(); !strings.Contains(body, "QUIET") {
+		t.Errorf("body %q", body)
+	}
+}
+
+func TestBasicRenderer(t *testing.T) {
+	setFlag(t, "renderer", "basic")
+
+	out, err := newRenderer().Render([]byte("a | b\n---|---\n1 | 2\n\n\"quoted\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "<table>") || strings.Contains(string(out), "“") {
+		t.Errorf("basic renderer used extensions: %q", out)
+	}
+
+	if validRenderer("nonsense") || !validRenderer("basic") || !validRenderer("blackfriday") {
+		t.Error("validRenderer does not match the renderers")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDGenerated(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About"})
+	logged := captureLog(t)
+	h := testHandler()
+
+	first, second := get(h, "/missing").Header().Get("X-Request-ID"), get(h, "/missing").Header().Get("X-Request-ID")
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(first) || first == second {
+		t.Errorf("generated IDs %q and %q", first, second)
+	}
+	if !strings.Contains(logged.String(), first) {
+		t.Errorf("log %q lacks the request ID %v", logged, first)
+	}
+}
+
+func TestRequestIDPreserved(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About"})
+	logged := captureLog(t)
+	h := testHandler()
+
+	if id := get(h, "/about", "X-Request-ID", "proxy-1234_a.b").Header().Get("X-Request-ID"); id != "proxy-1234_a.b" {
+		t.Errorf("X-Request-ID %q, want the proxy's", id)
+	}
+	if !strings.Contains(logged.String(), "proxy-1234_a.b") {
+		t.Errorf("log %q lacks the proxy's request ID", logged)
+	}
+
+	for _, id := range []string{"with space", "new\nline", strings.Repeat("a", 129)} {
+		if got := get(h, "/about", "X-Request-ID", id).Header().Get("X-Request-ID"); got == id || len(got) != 32 {
+			t.Errorf("X-Request-ID %q for %q, want a new one", got, id)
+		}
+	}
+}
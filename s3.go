@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var optBackend = flag.String("backend", "disk", "where the site is read from: disk, or s3 for an S3 compatible bucket")
+var optS3Bucket = flag.String("s3-bucket", "", "bucket the site is read from with -backend s3")
+var optS3Prefix = flag.String("s3-prefix", "", "key prefix of the site within -s3-bucket, e.g. mysite/")
+var optS3Region = flag.String("s3-region", "us-east-1", "region of -s3-bucket")
+var optS3Endpoint = flag.String("s3-endpoint", "", "URL of an S3 compatible service to use rather than AWS, e.g. http://localhost:9000")
+var optS3Refresh = flag.Duration("s3-refresh", time.Minute, "how often -s3-bucket is checked for changes; 0 to never check")
+
+// Objects up to this size are kept in memory once read, until they change.
+const s3CacheMaxObject = 1 << 20
+
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+}
+
+type s3ListResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+type s3Cached struct {
+	etag string
+	data []byte
+}
+
+// s3FS is a read only fs.FS of the objects in a bucket beneath a prefix.
+// The objects are listed up front, and again by refresh, so only reading
+// a file makes a request.
+type s3FS struct {
+	client *s3Client
+	prefix string
+
+	mu      sync.RWMutex
+	objects map[string]s3Object
+	dirs    map[string][]string
+
+	cacheLock sync.Mutex
+	cache     map[string]s3Cached
+}
+
+func newS3FS() (*s3FS, error) {
+	if *optS3Bucket == "" {
+		return nil, errors.New("-backend s3 requires -s3-bucket")
+	}
+
+	client := &s3Client{
+		bucket:    *optS3Bucket,
+		region:    *optS3Region,
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if *optS3Endpoint != "" {
+		var err error
+		if client.endpoint, err = url.Parse(*optS3Endpoint); err != nil {
+			return nil, fmt.Errorf("Invalid -s3-endpoint '%v': %v", *optS3Endpoint, err)
+		}
+	}
+
+	s := &s3FS{client: client, prefix: *optS3Prefix, cache: make(map[string]s3Cached)}
+	if s.prefix != "" && !strings.HasSuffix(s.prefix, "/") {
+		s.prefix += "/"
+	}
+	if _, err := s.refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// refresh lists the bucket again, returning whether any object was added,
+// removed or changed.
+func (s *s3FS) refresh() (bool, error) {
+	objects := make(map[string]s3Object)
+	dirs := map[string][]string{".": nil}
+
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+
+		b, err := s.client.get("", q)
+		if err != nil {
+			return false, fmt.Errorf("Failed to list bucket '%v': %v", *optS3Bucket, err)
+		}
+
+		var res s3ListResult
+		if err = xml.Unmarshal(b, &res); err != nil {
+			return false, fmt.Errorf("Failed to list bucket '%v': %v", *optS3Bucket, err)
+		}
+
+		for _, o := range res.Contents {
+			name := strings.TrimPrefix(o.Key, s.prefix)
+			if strings.HasSuffix(name, "/") || !fs.ValidPath(name) {
+				continue
+			}
+			objects[name] = o
+
+			for child, dir := name, path.Dir(name); ; child, dir = dir, path.Dir(dir) {
+				_, seen := dirs[dir]
+				dirs[dir] = append(dirs[dir], path.Base(child))
+				if seen || dir == "." {
+					break
+				}
+			}
+		}
+
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			break
+		}
+		token = res.NextContinuationToken
+	}
+
+	for dir := range dirs {
+		sort.Strings(dirs[dir])
+	}
+
+	s.mu.Lock()
+	changed := len(objects) != len(s.objects)
+	for name, o := range objects {
+		if old, ok := s.objects[name]; !ok || old.ETag != o.ETag {
+			changed = true
+		}
+	}
+	s.objects, s.dirs = objects, dirs
+	s.mu.Unlock()
+
+	return changed, nil
+}
+
+func (s *s3FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	s.mu.RLock()
+	o, isFile := s.objects[name]
+	children, isDir := s.dirs[name]
+	s.mu.RUnlock()
+
+	switch {
+	case isFile:
+		b, err := s.read(name, o)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &s3File{Reader: bytes.NewReader(b), info: s3Info{path.Base(name), o.Size, o.LastModified, false}}, nil
+	case isDir:
+		d := &s3Dir{info: s3Info{path.Base(name), 0, time.Time{}, true}}
+		for _, c := range children {
+			d.entries = append(d.entries, fs.FileInfoToDirEntry(s.stat(path.Join(name, c))))
+		}
+		return d, nil
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Stat answers from the listing, without reading the object.
+func (s *s3FS) Stat(name string) (fs.FileInfo, error) {
+	s.mu.RLock()
+	_, isFile := s.objects[name]
+	_, isDir := s.dirs[name]
+	s.mu.RUnlock()
+
+	if !fs.ValidPath(name) || (!isFile && !isDir) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return s.stat(name), nil
+}
+
+func (s *s3FS) stat(name string) s3Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if o, ok := s.objects[name]; ok {
+		return s3Info{path.Base(name), o.Size, o.LastModified, false}
+	}
+	return s3Info{path.Base(name), 0, time.Time{}, true}
+}
+
+func (s *s3FS) read(name string, o s3Object) ([]byte, error) {
+	s.cacheLock.Lock()
+	cached, ok := s.cache[name]
+	s.cacheLock.Unlock()
+
+	if ok && cached.etag == o.ETag {
+		return cached.data, nil
+	}
+
+	b, err := s.client.get(o.Key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cacheLock.Lock()
+	if len(b) <= s3CacheMaxObject {
+		s.cache[name] = s3Cached{o.ETag, b}
+	} else {
+		delete(s.cache, name)
+	}
+	s.cacheLock.Unlock()
+
+	return b, nil
+}
+
+// setupS3Refresh reloads the pages whenever the bucket has changed.
+func setupS3Refresh() {
+	s, ok := fsys.(*s3FS)
+	if !ok || *optS3Refresh <= 0 {
+		return
+	}
+
+	go func() {
+		for {
+			time.Sleep(*optS3Refresh)
+
+			changed, err := s.refresh()
+			if err != nil {
+				log.Println(err)
+			} else if changed {
+				log.Printf("Changed: bucket %v\n", *optS3Bucket)
+				reload()
+			}
+		}
+	}()
+}
+
+type s3Info struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i s3Info) Name() string       { return i.name }
+func (i s3Info) Size() int64        { return i.size }
+func (i s3Info) ModTime() time.Time { return i.modTime }
+func (i s3Info) IsDir() bool        { return i.dir }
+func (i s3Info) Sys() interface{}   { return nil }
+
+func (i s3Info) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+type s3File struct {
+	*bytes.Reader
+	info s3Info
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *s3File) Close() error               { return nil }
+
+type s3Dir struct {
+	info    s3Info
+	entries []fs.DirEntry
+}
+
+func (d *s3Dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *s3Dir) Close() error               { return nil }
+
+func (d *s3Dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *s3Dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// s3Client makes GET requests to a bucket, signed with AWS Signature
+// Version 4 when there are credentials in the environment.
+type s3Client struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	token     string
+}
+
+var s3HTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+func (c *s3Client) get(key string, q url.Values) ([]byte, error) {
+	u := &url.URL{Scheme: "https", Host: c.bucket + ".s3." + c.region + ".amazonaws.com", Path: "/" + key}
+	if c.endpoint != nil {
+		u = &url.URL{Scheme: c.endpoint.Scheme, Host: c.endpoint.Host,
+			Path: strings.TrimSuffix(c.endpoint.Path, "/") + "/" + c.bucket}
+		if key != "" {
+			u.Path += "/" + key
+		}
+	}
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = s3Query(q)
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.accessKey != "" {
+		c.sign(req, time.Now().UTC())
+	}
+
+	resp, err := s3HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(b) > 256 {
+			b = b[:256]
+		}
+		return nil, fmt.Errorf("%v: %s", resp.Status, b)
+	}
+	return b, nil
+}
+
+// emptyHash is the SHA-256 of the empty body of a GET request.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (c *s3Client) sign(req *http.Request, now time.Time) {
+	date := now.Format("20060102")
+	stamp := now.Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	if c.token != "" {
+		req.Header.Set("X-Amz-Security-Token", c.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	canonical := &strings.Builder{}
+	canonical.WriteString(req.Method + "\n" + req.URL.EscapedPath() + "\n" + req.URL.RawQuery + "\n")
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical.WriteString("\n" + signed + "\n" + emptyHash)
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{date, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%x",
+		c.accessKey, scope, signed, hmacSHA256(key, toSign)))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// s3Escape percent-encodes all but the unreserved characters, as AWS
+// requires in signed URLs, and slashes unless escapeSlash.
+func s3Escape(s string, escapeSlash bool) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query is the query string sorted by name and escaped, as signed.
+func s3Query(q url.Values) string {
+	names := make([]string, 0, len(q))
+	for k := range q {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, k := range names {
+		for _, v := range q[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeS3 serves the objects, keyed by name, of the bucket "site" the way
+// S3 does for path style requests, listing one object per page.
+func fakeS3(t *testing.T, objects map[string]string, reads *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			t.Errorf("request %v not signed: %q", r.URL, r.Header.Get("Authorization"))
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, "/site/")
+		if r.URL.Path == "/site" || r.URL.Path == "/site/" {
+			var keys []string
+			for k := range objects {
+				if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			i := 0
+			if token := r.URL.Query().Get("continuation-token"); token != "" {
+				fmt.Sscan(token, &i)
+			}
+			fmt.Fprint(w, "<ListBucketResult>")
+			if i < len(keys) {
+				fmt.Fprintf(w, "<Contents><Key>%v</Key><LastModified>2023-06-01T12:00:00Z</LastModified><ETag>\"%v\"</ETag><Size>%v</Size></Contents>", keys[i], len(objects[keys[i]]), len(objects[keys[i]]))
+			}
+			if i+1 < len(keys) {
+				fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%v</NextContinuationToken>", i+1)
+			}
+			fmt.Fprint(w, "</ListBucketResult>")
+			return
+		}
+
+		o, ok := objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		atomic.AddInt32(reads, 1)
+		fmt.Fprint(w, o)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestS3Backend(t *testing.T) {
+	const about = "---\ntitle: About\n---\nAbout *us*"
+	var reads int32
+	srv := fakeS3(t, map[string]string{
+		"mysite/layout.html":       testLayout,
+		"mysite/about.md":          about,
+		"mysite/blog/post.md":      "Post",
+		"mysite/.public/style.css": "body {}",
+		"other/secret.md":          "Secret",
+	}, &reads)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	setFlag(t, "s3-bucket", "site")
+	setFlag(t, "s3-prefix", "mysite")
+	setFlag(t, "s3-endpoint", srv.URL)
+
+	s, err := newS3FS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	useFS(t, s)
+	loadSite(t)
+	h := testHandler()
+
+	if body := get(h, "/about").Body.String(); !strings.Contains(body, "<title>About</title>") || !strings.Contains(body, "About <em>us</em>") {
+		t.Errorf("/about body %q", body)
+	}
+	if w := get(h, "/blog/post"); w.Code != 200 {
+		t.Errorf("/blog/post: status %v, want 200", w.Code)
+	}
+	if w := get(h, "/public/style.css"); w.Body.String() != "body {}" {
+		t.Errorf("/public/style.css body %q", w.Body.String())
+	}
+	if w := get(h, "/secret"); w.Code != 404 {
+		t.Errorf("/secret outside the prefix: status %v, want 404", w.Code)
+	}
+
+	info, err := s.Stat("about.md")
+	if err != nil || info.Size() != int64(len(about)) || !info.ModTime().Equal(time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Stat = %v, %v", info, err)
+	}
+
+	// unchanged objects are read from the cache
+	before := atomic.LoadInt32(&reads)
+	loadSite(t)
+	if after := atomic.LoadInt32(&reads); after != before {
+		t.Errorf("%v objects read again on reloading an unchanged bucket", after-before)
+	}
+	if changed, err := s.refresh(); err != nil || changed {
+		t.Errorf("refresh of an unchanged bucket = %v, %v", changed, err)
+	}
+}
+
+func TestS3BackendRequiresBucket(t *testing.T) {
+	setFlag(t, "s3-bucket", "")
+	if _, err := newS3FS(); err == nil {
+		t.Error("newS3FS without -s3-bucket succeeded")
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var optSanitize = flag.Bool("sanitize", true, "strip scripts, event handlers and unsafe URLs from rendered pages")
+
+// sanitizer allows the formatting markdown produces, including raw HTML
+// such as links and images, the classes used by code highlighting and the
+// checkboxes of task lists.
+var sanitizer = newSanitizer()
+
+func newSanitizer() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowStyling()
+	p.RequireNoFollowOnLinks(false)
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+func sanitize(content []byte) []byte {
+	if !*optSanitize {
+		return content
+	}
+	return sanitizer.SanitizeBytes(content)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const unsafePage = "Hello <script>alert('x')</script><a href=\"/about\" onclick=\"steal()\">about</a> [home](/) [bad](javascript:alert(1))\n"
+
+func TestSanitizedPages(t *testing.T) {
+	d := testSite(t, map[string]string{"page.md": unsafePage})
+
+	content := string(d.Files["page"].Content)
+	for _, unsafe := range []string{"<script", "alert('x')", "onclick", "steal()", "javascript:"} {
+		if strings.Contains(content, unsafe) {
+			t.Errorf("content %q still holds %v", content, unsafe)
+		}
+	}
+	for _, safe := range []string{`<a href="/about">about</a>`, `<a href="/">home</a>`} {
+		if !strings.Contains(content, safe) {
+			t.Errorf("content %q lost %v", content, safe)
+		}
+	}
+}
+
+func TestUnsanitizedPages(t *testing.T) {
+	setFlag(t, "sanitize", "false")
+	d := testSite(t, map[string]string{"page.md": unsafePage})
+
+	if content := string(d.Files["page"].Content); !strings.Contains(content, "<script>") || !strings.Contains(content, "onclick") {
+		t.Errorf("content %q was sanitized with -sanitize=false", content)
+	}
+}
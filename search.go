@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// searchIndex maps each token to the pages containing it and the number
+// of times it occurs in each.
+type searchIndex struct {
+	pages  []searchResult
+	tokens map[string]map[int]int
+}
+
+type searchResult struct {
+	Path    string `json:"path"`
+	Title   string `json:"title,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Score   int    `json:"score"`
+}
+
+var search *searchIndex
+var searchLock sync.RWMutex
+
+func setupSearch(mux *http.ServeMux) {
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		searchLock.RLock()
+		idx := search
+		searchLock.RUnlock()
+
+		q := r.URL.Query().Get("q")
+		results := idx.Find(q)
+
+		if wantsJSON(r) {
+			b, _ := json.Marshal(results)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-cache")
+			setCORS(w, r)
+			w.Write(b)
+			return
+		}
+
+		b := &strings.Builder{}
+		b.WriteString("<h2>Search results for &ldquo;" + html.EscapeString(q) + "&rdquo;</h2>\n")
+		if len(results) == 0 {
+			b.WriteString("<p>No pages found.</p>\n")
+		} else {
+			b.WriteString("<ol class=\"search\">\n")
+			for _, res := range results {
+				title := res.Title
+				if title == "" {
+					title = res.Path
+				}
+				b.WriteString("<li><a href=\"" + html.EscapeString(res.Path) + "\">" + html.EscapeString(title) + "</a>")
+				if res.Summary != "" {
+					b.WriteString("<p>" + html.EscapeString(res.Summary) + "</p>")
+				}
+				b.WriteString("</li>\n")
+			}
+			b.WriteString("</ol>\n")
+		}
+
+		cf := &ContentFile{Content: []byte(b.String())}
+		cf.Title = "Search"
+		cf.CacheControl = "no-cache"
+		write(w, r, 200, cf, getTree().Layout)
+	})
+}
+
+func updateSearch(d *Dir) {
+	idx := buildSearch(d)
+
+	searchLock.Lock()
+	search = idx
+	searchLock.Unlock()
+}
+
+// tokenize splits s into lowercase runs of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func buildSearch(d *Dir) *searchIndex {
+	idx := &searchIndex{tokens: make(map[string]map[int]int)}
+
+	d.Walk(func(p string, dir *Dir) {
+		for _, n := range dir.FileNames() {
+			cf := dir.Files[n]
+			if (p == "/" && n == *optNotFound) || (cf.Draft && !*optShowDrafts) {
+				continue
+			}
+
+			u := linkURL(dir, p, n)
+			if protected(u) {
+				continue
+			}
+
+			id := len(idx.pages)
+			idx.pages = append(idx.pages, searchResult{Path: u, Title: cf.Title, Summary: cf.Summary})
+
+			text := []string{cf.Title, cf.Summary, strings.Join(cf.Tags, " "),
+				html.UnescapeString(string(tagPattern.ReplaceAll(cf.Content, []byte(" "))))}
+
+			for _, t := range tokenize(strings.Join(text, " ")) {
+				if idx.tokens[t] == nil {
+					idx.tokens[t] = make(map[int]int)
+				}
+				idx.tokens[t][id]++
+			}
+		}
+	})
+
+	return idx
+}
+
+// Find returns the pages containing every term of q, those with the most
+// occurrences of the terms first.
+func (idx *searchIndex) Find(q string) []searchResult {
+	terms := tokenize(q)
+	if idx == nil || len(terms) == 0 {
+		return nil
+	}
+
+	scores := make(map[int]int)
+	for id, n := range idx.tokens[terms[0]] {
+		scores[id] = n
+	}
+
+	for _, t := range terms[1:] {
+		pages := idx.tokens[t]
+		for id := range scores {
+			if n, ok := pages[id]; ok {
+				scores[id] += n
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]searchResult, 0, len(scores))
+	for id, score := range scores {
+		res := idx.pages[id]
+		res.Score = score
+		results = append(results, res)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+
+	return results
+}
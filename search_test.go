@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func searchPaths(t *testing.T, q string) []string {
+	t.Helper()
+
+	w := get(testHandler(), "/search?format=json&q="+q)
+	if w.Code != 200 {
+		t.Fatalf("status %v, want 200", w.Code)
+	}
+
+	var results []searchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make([]string, 0, len(results))
+	for _, res := range results {
+		paths = append(paths, res.Path)
+	}
+	return paths
+}
+
+func TestSearchSingleTerm(t *testing.T) {
+	testSite(t, map[string]string{
+		"once.md":  "A gopher.",
+		"twice.md": "A gopher and another gopher.",
+		"none.md":  "A badger.",
+	})
+
+	if got, want := searchPaths(t, "gopher"), []string{"/twice", "/once"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("results %v, want %v", got, want)
+	}
+}
+
+func TestSearchMultipleTerms(t *testing.T) {
+	testSite(t, map[string]string{
+		"both.md":   "Gopher meets badger, badger meets gopher, gopher wins.",
+		"some.md":   "Gopher meets badger.",
+		"gopher.md": "Gopher gopher gopher gopher.",
+	})
+
+	if got, want := searchPaths(t, "gopher+badger"), []string{"/both", "/some"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("results %v, want %v", got, want)
+	}
+}
+
+func TestSearchLeavesOutProtectedPages(t *testing.T) {
+	setFlag(t, "auth-prefix", "/blog/")
+	setFlag(t, "auth-user", "user")
+	setFlag(t, "auth-pass", "pass")
+	testSite(t, map[string]string{
+		"about.md":      "A post about us.",
+		"blog/post1.md": "A secret post.",
+	})
+
+	if got, want := searchPaths(t, "post"), []string{"/about"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("results %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+)
+
+var optNoSniff = flag.Bool("nosniff", true, "send X-Content-Type-Options: nosniff with rendered pages")
+var optFrameOptions = flag.String("frame-options", "SAMEORIGIN", "X-Frame-Options header sent with rendered pages; empty to disable")
+var optReferrerPolicy = flag.String("referrer-policy", "strict-origin-when-cross-origin", "Referrer-Policy header sent with rendered pages; empty to disable")
+var optCSP = flag.String("csp", "", "Content-Security-Policy header sent with rendered pages")
+
+func setSecurityHeaders(h http.Header) {
+	if *optNoSniff {
+		h.Set("X-Content-Type-Options", "nosniff")
+	}
+	if *optFrameOptions != "" {
+		h.Set("X-Frame-Options", *optFrameOptions)
+	}
+	if *optReferrerPolicy != "" {
+		h.Set("Referrer-Policy", *optReferrerPolicy)
+	}
+	if *optCSP != "" {
+		h.Set("Content-Security-Policy", *optCSP)
+	}
+}
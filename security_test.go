@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	setFlag(t, "csp", "default-src 'self'")
+	testSite(t, map[string]string{"about.md": "About"})
+
+	w := get(http.HandlerFunc(renderPage), "/about")
+	for name, want := range map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "SAMEORIGIN",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+		"Content-Security-Policy": "default-src 'self'",
+	} {
+		if v := w.Header().Get(name); v != want {
+			t.Errorf("%v %q, want %q", name, v, want)
+		}
+	}
+}
+
+func TestSecurityHeadersDisabled(t *testing.T) {
+	setFlag(t, "nosniff", "false")
+	setFlag(t, "frame-options", "")
+	setFlag(t, "referrer-policy", "")
+	setFlag(t, "csp", "")
+	testSite(t, map[string]string{"about.md": "About"})
+
+	w := get(http.HandlerFunc(renderPage), "/about")
+	for _, name := range []string{"X-Content-Type-Options", "X-Frame-Options", "Referrer-Policy", "Content-Security-Policy"} {
+		if v := w.Header().Get(name); v != "" {
+			t.Errorf("%v %q, want none when disabled", name, v)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var SiteFilename = "site.json"
+
+// site holds the variables from the site file, used in layouts as
+// {{site.name}}, with dots separating the names of nested objects.
+var site map[string]interface{}
+var siteLock sync.RWMutex
+
+func updateSite() error {
+	vars := make(map[string]interface{})
+
+	b, err := readFile(filepath.Join(root, SiteFilename))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err == nil {
+		if err = json.Unmarshal(b, &vars); err != nil {
+			return err
+		}
+	}
+
+	siteLock.Lock()
+	site = vars
+	siteLock.Unlock()
+	return nil
+}
+
+// siteVariable looks up a site variable by its dotted name.
+func siteVariable(name string) (interface{}, bool) {
+	siteLock.RLock()
+	defer siteLock.RUnlock()
+
+	var v interface{} = site
+	for _, n := range strings.Split(name, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[n]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// siteString returns the site variable as a string, or "" if it is not
+// defined or not a string.
+func siteString(name string) string {
+	v, _ := siteVariable(name)
+	s, _ := v.(string)
+	return s
+}
+
+// siteValue returns the escaped value of the variable named by a
+// {{site.name}} placeholder.
+func siteValue(token string) (string, bool, error) {
+	if !strings.HasPrefix(token, "site.") {
+		return "", false, nil
+	}
+
+	v, ok := siteVariable(token[len("site."):])
+	if !ok {
+		return "", true, fmt.Errorf("undefined site variable {{%v}}", token)
+	}
+
+	switch v.(type) {
+	case string, float64, bool:
+		return html.EscapeString(fmt.Sprint(v)), true, nil
+	}
+
+	return "", true, fmt.Errorf("site variable {{%v}} is not a string, number or boolean", token)
+}
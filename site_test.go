@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSiteVariables(t *testing.T) {
+	testSite(t, map[string]string{
+		"site.json":   `{"name": "Fish & Chips", "social": {"mastodon": "@fish"}, "since": 2019, "open": true}`,
+		"layout.html": "<title>{{title}} - {{ site.name }}</title>{{content}}{{site.social.mastodon}} {{site.since}} {{site.open}}",
+		"about.md":    "About",
+	})
+
+	body := get(http.HandlerFunc(renderPage), "/about").Body.String()
+	if !strings.HasPrefix(body, "<title> - Fish &amp; Chips</title>") || !strings.HasSuffix(body, "@fish 2019 true") {
+		t.Errorf("body %q", body)
+	}
+}
+
+func TestSiteVariableErrors(t *testing.T) {
+	writeSite(t, map[string]string{"site.json": `{"name": "Fish", "social": {"mastodon": "@fish"}}`})
+	if err := updateSite(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { site = nil })
+
+	for layout, want := range map[string]string{
+		"{{content}}{{site.title}}":      "undefined site variable {{site.title}}",
+		"{{content}}{{site.name.first}}": "undefined site variable {{site.name.first}}",
+		"{{content}}{{site.social}}":     "is not a string, number or boolean",
+	} {
+		if _, err := parseLayout([]byte(layout)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parsing %q: error %v, want one saying %q", layout, err, want)
+		}
+	}
+
+	writeSite(t, map[string]string{"site.json": `{"name": `})
+	if err := updateSite(); err == nil {
+		t.Error("reading an invalid site file succeeded")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var optBaseURL = flag.String("base-url", "", "absolute URL the site is served from, e.g. https://example.com")
+
+// sitemapURLs holds the URL paths of the pages; sitemap is the sitemap of
+// them at -base-url, or nil when it is not set and the sitemap is made for
+// the host each request is made to.
+var sitemapURLs []sitemapURL
+var sitemap []byte
+var sitemapLock sync.RWMutex
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func setupSitemap(mux *http.ServeMux) {
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		sitemapLock.RLock()
+		b, urls := sitemap, sitemapURLs
+		sitemapLock.RUnlock()
+
+		if b == nil {
+			b = marshalSitemap(urls, requestBase(r))
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		w.Header().Set("Content-Type", "application/xml; charset=UTF-8")
+		w.Write(b)
+	})
+}
+
+func updateSitemap(d *Dir) {
+	urls := buildSitemap(d)
+
+	var b []byte
+	if *optBaseURL != "" {
+		b = marshalSitemap(urls, *optBaseURL)
+	}
+
+	sitemapLock.Lock()
+	sitemapURLs, sitemap = urls, b
+	sitemapLock.Unlock()
+}
+
+// requestBase is the scheme and host r was made to, taking the scheme from
+// X-Forwarded-Proto behind a -trust-proxy.
+func requestBase(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || (*optTrustProxy && r.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// pageURL returns the URL path of the named page in the directory d at p,
+// using the directory itself for its default page.
+func pageURL(d *Dir, p, name string) string {
+	if name == d.defaultName() {
+		return p
+	}
+	return p + name
+}
+
+func absoluteURL(p string) string {
+	return strings.TrimRight(*optBaseURL, "/") + p
+}
+
+// buildSitemap lists the pages anyone may see, with their URL paths.
+func buildSitemap(d *Dir) []sitemapURL {
+	var urls []sitemapURL
+
+	d.Walk(func(p string, dir *Dir) {
+		for _, n := range dir.FileNames() {
+			if p == "/" && n == *optNotFound {
+				continue
+			}
+
+			cf := dir.Files[n]
+			if cf.Draft && !*optShowDrafts {
+				continue
+			}
+
+			u := sitemapURL{Loc: linkURL(dir, p, n)}
+			if protected(u.Loc) {
+				continue
+			}
+			if !cf.ModTime.IsZero() {
+				u.LastMod = cf.ModTime.UTC().Format(time.RFC3339)
+			}
+			urls = append(urls, u)
+		}
+	})
+
+	return urls
+}
+
+// marshalSitemap writes the sitemap of the URL paths at base.
+func marshalSitemap(urls []sitemapURL, base string) []byte {
+	set := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, u := range urls {
+		u.Loc = strings.TrimRight(base, "/") + u.Loc
+		set.URLs = append(set.URLs, u)
+	}
+
+	b, _ := xml.MarshalIndent(set, "", "  ")
+	return append([]byte(xml.Header), b...)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// sitemapLocs decodes the sitemap, checking it has the elements of the
+// sitemap schema, and returns its URLs.
+func sitemapLocs(t *testing.T, b []byte) []string {
+	t.Helper()
+
+	var set struct {
+		XMLName xml.Name
+		URLs    []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(b, &set); err != nil {
+		t.Fatal(err)
+	}
+
+	if set.XMLName.Space != "http://www.sitemaps.org/schemas/sitemap/0.9" || set.XMLName.Local != "urlset" {
+		t.Errorf("root element %v, want the sitemap urlset", set.XMLName)
+	}
+
+	var locs []string
+	for _, u := range set.URLs {
+		if u.Loc == "" || u.LastMod == "" {
+			t.Errorf("url %+v lacks loc or lastmod", u)
+		}
+		locs = append(locs, u.Loc)
+	}
+	return locs
+}
+
+func TestSitemapListsPages(t *testing.T) {
+	setFlag(t, "base-url", "https://example.org/")
+	testSite(t, map[string]string{
+		"index.md":         "Home",
+		"about.md":         "About",
+		"404.md":           "Not found",
+		"blog/draft.md":    "---\ndraft: true\n---\nDraft",
+		"blog/articles.md": "Articles",
+	})
+
+	w := get(testHandler(), "/sitemap.xml")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Errorf("Content-Type %q", ct)
+	}
+
+	locs := strings.Join(sitemapLocs(t, w.Body.Bytes()), " ")
+	if want := "https://example.org/about https://example.org/ https://example.org/blog/articles"; locs != want {
+		t.Errorf("urls %q, want %q", locs, want)
+	}
+}
+
+func TestSitemapUsesRequestHostWithoutBaseURL(t *testing.T) {
+	testSite(t, map[string]string{"about.md": "About"})
+
+	w := get(testHandler(), "http://site.test/sitemap.xml")
+	if locs := sitemapLocs(t, w.Body.Bytes()); len(locs) != 1 || locs[0] != "http://site.test/about" {
+		t.Errorf("urls %q, want http://site.test/about", locs)
+	}
+}
+
+func TestSitemapLeavesOutProtectedPages(t *testing.T) {
+	setFlag(t, "base-url", "https://example.org")
+	setFlag(t, "auth-prefix", "/blog/")
+	setFlag(t, "auth-user", "user")
+	setFlag(t, "auth-pass", "pass")
+	testSite(t, map[string]string{
+		"about.md":      "About",
+		"blog/post1.md": "Post",
+	})
+
+	if locs := sitemapLocs(t, get(testHandler(), "/sitemap.xml").Body.Bytes()); len(locs) != 1 || locs[0] != "https://example.org/about" {
+		t.Errorf("urls %q, want only /about", locs)
+	}
+}
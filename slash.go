@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"html"
+	"strings"
+)
+
+var optCanonicalSlash = flag.String("canonical-slash", "", "redirect pages to their canonical URL; 'strip' or 'add' a trailing slash")
+
+func validCanonicalSlash(s string) bool {
+	return s == "" || s == "strip" || s == "add"
+}
+
+// canonicalPath returns the URL path p should be redirected to, if any.
+// Directories always end in a slash; the policy only applies to pages.
+func canonicalPath(tree *Dir, p string) (string, bool) {
+	if *optCanonicalSlash == "" || p == "/" {
+		return "", false
+	}
+
+	trimmed := strings.TrimRight(p, "/")
+	canonical := ""
+
+	if d := tree.FindByPath(trimmed + "/"); d != nil {
+		canonical = trimmed + "/"
+	} else if _, cf := findPage(tree, trimmed); cf != nil {
+		canonical = trimmed
+		if *optCanonicalSlash == "add" {
+			canonical += "/"
+		}
+	}
+
+	return canonical, canonical != "" && canonical != p
+}
+
+// linkURL returns the URL path pages are linked to the named page in the
+// directory at p with, which is served without a redirect.
+func linkURL(d *Dir, p, name string) string {
+	u := pageURL(d, p, name)
+	if *optCanonicalSlash == "add" && !strings.HasSuffix(u, "/") {
+		u += "/"
+	}
+	return u
+}
+
+// canonicalURL returns the absolute URL of the named page in the directory
+// at p.
+func canonicalURL(d *Dir, p, name string) string {
+	return absoluteURL(linkURL(d, p, name))
+}
+
+func canonicalHTML(u string) []byte {
+	return []byte(`<link rel="canonical" href="` + html.EscapeString(u) + `">`)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalSlash(t *testing.T) {
+	testSite(t, map[string]string{
+		"about.md":      "About",
+		"blog/index.md": "Blog",
+	})
+
+	for policy, redirects := range map[string]map[string]string{
+		"": {},
+		"strip": {
+			"/about/":      "/about",
+			"/about/?a=1":  "/about?a=1",
+			"/blog":        "/blog/",
+			"/missing/":    "",
+			"/about":       "",
+			"/blog/?a=b&c": "",
+		},
+		"add": {
+			"/about":     "/about/",
+			"/about?a=1": "/about/?a=1",
+			"/blog":      "/blog/",
+			"/about/":    "",
+		},
+	} {
+		setFlag(t, "canonical-slash", policy)
+		for u, want := range redirects {
+			w := get(http.HandlerFunc(renderPage), u)
+			if want == "" {
+				if w.Code == http.StatusMovedPermanently {
+					t.Errorf("-canonical-slash=%v: %v redirected to %q", policy, u, w.Header().Get("Location"))
+				}
+			} else if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != want {
+				t.Errorf("-canonical-slash=%v: %v is %v to %q, want a redirect to %q", policy, u, w.Code, w.Header().Get("Location"), want)
+			}
+		}
+	}
+
+	setFlag(t, "canonical-slash", "")
+	if w := get(http.HandlerFunc(renderPage), "/about"); w.Code != http.StatusOK {
+		t.Errorf("without a policy /about is %v, want 200", w.Code)
+	}
+}
+
+func TestLinkURLFollowsPolicy(t *testing.T) {
+	d := testSite(t, map[string]string{"blog/post.md": "Post"})
+	blog := d.Directories["blog"]
+
+	setFlag(t, "canonical-slash", "strip")
+	if u := linkURL(blog, "/blog/", "post"); u != "/blog/post" {
+		t.Errorf("linkURL with strip = %q", u)
+	}
+	setFlag(t, "canonical-slash", "add")
+	if u := linkURL(blog, "/blog/", "post"); u != "/blog/post/" {
+		t.Errorf("linkURL with add = %q", u)
+	}
+}
+
+func TestCanonicalLinks(t *testing.T) {
+	setFlag(t, "base-url", "https://example.com")
+	setFlag(t, "canonical-slash", "add")
+	d := testSite(t, map[string]string{
+		"layout.html":  "<head>{{canonical}}</head>{{content}}",
+		"index.md":     "Home",
+		"blog/post.md": "---\ndate: 2023-06-01\n---\nPost",
+	})
+
+	if got := string(d.Files["index"].CanonicalHTML); got != `<link rel="canonical" href="https://example.com/">` {
+		t.Errorf("index page canonical link %q", got)
+	}
+	if got := string(d.Directories["blog"].Files["post"].CanonicalHTML); got != `<link rel="canonical" href="https://example.com/blog/post/">` {
+		t.Errorf("post canonical link %q", got)
+	}
+
+	body := get(http.HandlerFunc(renderPage), "/blog/2023/").Body.String()
+	if !strings.HasPrefix(body, `<head><link rel="canonical" href="https://example.com/blog/2023/"></head>`) {
+		t.Errorf("archive %q does not link to its canonical URL", body)
+	}
+}
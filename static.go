@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type staticHash struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+var staticHashes = make(map[string]staticHash)
+var staticHashesLock sync.Mutex
+
+// staticETag sets an Etag of the content hash of the file in dir named by
+// the request path, which http.ServeContent then answers If-None-Match
+// with. Hashes are kept until the file's modification time or size change.
+func staticETag(dir string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if w.Header().Get("Etag") != "" {
+			// set by compressStatic for the compressed file
+		} else if etag, ok := fileETag(filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))); ok {
+			w.Header().Set("Etag", etag)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func fileETag(p string) (string, bool) {
+	f, err := openFile(p)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+
+	staticHashesLock.Lock()
+	cached, ok := staticHashes[p]
+	staticHashesLock.Unlock()
+
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.etag, true
+	}
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", false
+	}
+
+	etag := fmt.Sprintf("\"%x\"", hash(b))
+
+	staticHashesLock.Lock()
+	staticHashes[p] = staticHash{info.ModTime(), info.Size(), etag}
+	staticHashesLock.Unlock()
+
+	return etag, true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticETag(t *testing.T) {
+	testSite(t, map[string]string{".public/style.css": "body { color: black }"})
+	h := testHandler()
+
+	w := get(h, "/public/style.css")
+	etag := w.Header().Get("Etag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status %v, Etag %q", w.Code, etag)
+	}
+	if etag[0] != '"' {
+		t.Errorf("Etag %q is not quoted", etag)
+	}
+
+	if w := get(h, "/public/style.css", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("request with the Etag: status %v, want 304", w.Code)
+	}
+
+	// a changed file gets a new Etag
+	p := filepath.Join(root, ".public", "style.css")
+	if err := os.WriteFile(p, []byte("body { color: white; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(p, time.Now(), time.Now().Add(time.Second))
+	if w := get(h, "/public/style.css", "If-None-Match", etag); w.Code != http.StatusOK || w.Header().Get("Etag") == etag {
+		t.Errorf("request after a change: status %v, Etag %q", w.Code, w.Header().Get("Etag"))
+	}
+}
+
+func TestManifestAndServiceWorker(t *testing.T) {
+	testSite(t, map[string]string{
+		".public/manifest.json":     `{"name": "Site"}`,
+		".public/service-worker.js": "self.addEventListener('fetch', function() {})",
+	})
+	h := testHandler()
+
+	w := get(h, "/manifest.json")
+	if ct := w.Header().Get("Content-Type"); w.Code != 200 || ct != "application/manifest+json" {
+		t.Errorf("/manifest.json: status %v, Content-Type %q", w.Code, ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != *optStaticCacheControl {
+		t.Errorf("/manifest.json: Cache-Control %q", cc)
+	}
+
+	w = get(h, "/service-worker.js")
+	if ct := w.Header().Get("Content-Type"); w.Code != 200 || ct != "text/javascript; charset=UTF-8" {
+		t.Errorf("/service-worker.js: status %v, Content-Type %q", w.Code, ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("/service-worker.js: Cache-Control %q, want no-cache", cc)
+	}
+}
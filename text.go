@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var blockEndPattern = regexp.MustCompile(`(?i)</(p|h[1-6]|li|dt|dd|pre|div|blockquote|tr|table|ul|ol)>|<(br|hr)\s*/?>`)
+var blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+// wantsText reports whether the client asked for a page as plain text,
+// with ?format=txt, or ?format=md for its markdown source, or an Accept
+// header naming text/plain.
+func wantsText(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "txt" || f == "md"
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if t, _, err := mime.ParseMediaType(accept); err == nil && t == "text/plain" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pageText is the text of rendered HTML, with a line break after each
+// block and the tags removed.
+func pageText(content []byte) []byte {
+	b := blockEndPattern.ReplaceAll(content, []byte("\n"))
+	b = []byte(html.UnescapeString(string(tagPattern.ReplaceAll(b, nil))))
+	b = blankLinesPattern.ReplaceAll(b, []byte("\n\n"))
+	return append(bytes.TrimSpace(b), '\n')
+}
+
+func writeText(w http.ResponseWriter, r *http.Request, cf *ContentFile) {
+	var b []byte
+	switch {
+	case cf.Raw:
+		b = cf.Content
+	case r.URL.Query().Get("format") == "md":
+		b = cf.Source
+	default:
+		b = pageText(cf.Content)
+		if cf.Title != "" && !bytes.HasPrefix(b, []byte(cf.Title)) {
+			b = append([]byte(cf.Title+"\n\n"), b...)
+		}
+	}
+
+	if cc := cf.CacheControl; cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	} else if *optCacheControl != "" {
+		w.Header().Set("Cache-Control", *optCacheControl)
+	}
+
+	w.Header().Set("Vary", "Accept, Accept-Encoding, Accept-Language")
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(b)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPageAsText(t *testing.T) {
+	const source = "# Fish &amp; Chips\n\nA *classic*.\n\n- fish\n- chips\n"
+	testSite(t, map[string]string{"recipe.md": "---\ntitle: Recipe\n---\n" + source})
+	h := http.HandlerFunc(renderPage)
+
+	w := get(h, "/recipe?format=md")
+	if w.Body.String() != source || w.Header().Get("Content-Type") != "text/plain; charset=UTF-8" {
+		t.Errorf("?format=md: Content-Type %q, body %q; want the source without front matter", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	want := "Recipe\n\nFish & Chips\n\nA classic.\n\nfish\n\nchips\n"
+	if body := get(h, "/recipe?format=txt").Body.String(); body != want {
+		t.Errorf("?format=txt: body %q, want %q", body, want)
+	}
+	if body := get(h, "/recipe", "Accept", "text/plain").Body.String(); body != want {
+		t.Errorf("Accept: text/plain: body %q, want %q", body, want)
+	}
+
+	if ct := get(h, "/recipe").Header().Get("Content-Type"); ct != "text/html; charset=UTF-8" {
+		t.Errorf("HTML page Content-Type %q", ct)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var optServerTiming = flag.Bool("server-timing", false, "send a Server-Timing header with whether pages were cached and how long they took to render and compress")
+
+// serverTiming holds the metrics of a page's Server-Timing header.
+type serverTiming []string
+
+func (t *serverTiming) cache(hit bool) {
+	desc := "miss"
+	if hit {
+		desc = "hit"
+	}
+	*t = append(*t, `cache;desc="`+desc+`"`)
+}
+
+// since records the time taken by the named phase, which began at start.
+func (t *serverTiming) since(name string, start time.Time) {
+	ms := float64(time.Since(start).Microseconds()) / 1000
+	*t = append(*t, name+";dur="+strconv.FormatFloat(ms, 'f', 3, 64))
+}
+
+func (t serverTiming) set(h http.Header) {
+	if *optServerTiming && len(t) > 0 {
+		h.Set("Server-Timing", strings.Join(t, ", "))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestServerTiming(t *testing.T) {
+	testSite(t, map[string]string{
+		"layout.print.html": "<pre>{{content}}</pre>",
+		"about.md":          strings.Repeat("About. ", 300),
+	})
+	h := http.HandlerFunc(renderPage)
+
+	if st := get(h, "/about").Header().Get("Server-Timing"); st != "" {
+		t.Errorf("Server-Timing %q without -server-timing", st)
+	}
+
+	setFlag(t, "server-timing", "true")
+	if st := get(h, "/about", "Accept-Encoding", "gzip").Header().Get("Server-Timing"); st != `cache;desc="hit"` {
+		t.Errorf("prerendered page Server-Timing %q", st)
+	}
+
+	st := get(h, "/about?view=print", "Accept-Encoding", "gzip").Header().Get("Server-Timing")
+	if !regexp.MustCompile(`^cache;desc="miss", render;dur=\d+\.\d{3}, compress;dur=\d+\.\d{3}$`).MatchString(st) {
+		t.Errorf("page in another view Server-Timing %q", st)
+	}
+}
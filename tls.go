@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var optTLSCert = flag.String("tls-cert", "", "path of the TLS certificate; enables HTTPS together with -tls-key")
+var optTLSKey = flag.String("tls-key", "", "path of the TLS private key")
+var optAutocertDomains = flag.String("autocert-domains", "", "comma separated domains to obtain certificates for from Let's Encrypt")
+var optAutocertCache = flag.String("autocert-cache", ".autocert", "directory where Let's Encrypt certificates are cached")
+var optRedirectAddr = flag.String("http-redirect-addr", "", "address of a plain HTTP listener redirecting to HTTPS")
+var optH2C = flag.Bool("h2c", false, "serve HTTP/2 without TLS as well as HTTP/1.1, for proxies which speak it")
+
+// setupTLS configures srv for HTTPS when requested and returns the server
+// for the HTTP redirect listener, or nil if there should not be one.
+func setupTLS(srv *http.Server) *http.Server {
+	switch {
+	case *optTLSCert != "" && *optAutocertDomains != "":
+		log.Fatalf("-tls-cert and -autocert-domains cannot be used together")
+	case (*optTLSCert == "") != (*optTLSKey == ""):
+		log.Fatalf("-tls-cert and -tls-key must be used together")
+	case *optTLSCert == "" && *optAutocertDomains == "":
+		if *optRedirectAddr != "" {
+			log.Fatalf("-http-redirect-addr requires HTTPS to be enabled")
+		}
+		return nil
+	}
+
+	var redirect http.Handler = http.HandlerFunc(redirectHTTPS)
+
+	if *optAutocertDomains != "" {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(*optAutocertDomains, ",")...),
+			Cache:      autocert.DirCache(*optAutocertCache),
+		}
+
+		srv.TLSConfig = m.TLSConfig()
+		redirect = m.HTTPHandler(redirect)
+	}
+
+	if *optRedirectAddr == "" {
+		return nil
+	}
+
+	return &http.Server{Addr: *optRedirectAddr, Handler: redirect}
+}
+
+// setupH2C lets srv serve HTTP/2 over cleartext connections, which clients
+// begin with the HTTP/2 preface. HTTPS negotiates HTTP/2 itself.
+func setupH2C(srv *http.Server) {
+	if !*optH2C {
+		return
+	}
+	if *optTLSCert != "" || *optAutocertDomains != "" {
+		log.Fatalf("-h2c cannot be used with HTTPS, which serves HTTP/2 already")
+	}
+
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+}
+
+func serve(srv *http.Server) error {
+	switch {
+	case *optTLSCert != "":
+		return srv.ListenAndServeTLS(*optTLSCert, *optTLSKey)
+	case *optAutocertDomains != "":
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	return srv.ListenAndServe()
+}
+
+func redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if _, port, err := net.SplitHostPort(*optAddr); err == nil && port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
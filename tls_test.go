@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectListener(t *testing.T) {
+	setFlag(t, "tls-cert", "cert.pem")
+	setFlag(t, "tls-key", "key.pem")
+	setFlag(t, "http-redirect-addr", ":8080")
+
+	for addr, want := range map[string]string{
+		":443":  "https://example.com/about?page=2",
+		":8443": "https://example.com:8443/about?page=2",
+	} {
+		setFlag(t, "addr", addr)
+
+		redirect := setupTLS(&http.Server{})
+		if redirect == nil || redirect.Addr != ":8080" {
+			t.Fatalf("redirect listener %+v, want one at :8080", redirect)
+		}
+
+		w := get(redirect.Handler, "http://example.com:8080/about?page=2")
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("-addr %v: status %v, want 301", addr, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != want {
+			t.Errorf("-addr %v: Location %v, want %v", addr, loc, want)
+		}
+	}
+}
+
+func TestNoRedirectListenerWithoutRedirectAddr(t *testing.T) {
+	setFlag(t, "tls-cert", "cert.pem")
+	setFlag(t, "tls-key", "key.pem")
+	setFlag(t, "http-redirect-addr", "")
+
+	if redirect := setupTLS(&http.Server{}); redirect != nil {
+		t.Errorf("redirect listener %+v, want none", redirect)
+	}
+}
+
+func TestH2C(t *testing.T) {
+	setFlag(t, "h2c", "true")
+	testSite(t, map[string]string{"about.md": "About"})
+
+	srv := httptest.NewUnstartedServer(testHandler())
+	setupH2C(srv.Config)
+	srv.Start()
+	defer srv.Close()
+
+	for _, h2 := range []bool{true, false} {
+		p := new(http.Protocols)
+		p.SetUnencryptedHTTP2(h2)
+		p.SetHTTP1(!h2)
+		client := &http.Client{Transport: &http.Transport{Protocols: p}}
+
+		res, err := client.Get(srv.URL + "/about")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		want := 1
+		if h2 {
+			want = 2
+		}
+		if res.StatusCode != 200 || res.ProtoMajor != want {
+			t.Errorf("status %v over %v, want 200 over HTTP/%v", res.StatusCode, res.Proto, want)
+		}
+	}
+}
@@ -3,17 +3,23 @@ package main
 import (
 	"bytes"
 	"crypto/md5"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
-
-	md "github.com/russross/blackfriday"
+	"sync"
+	"time"
 )
 
 var LayoutFilename = "layout.html"
 
+var optWorkers = flag.Int("workers", runtime.NumCPU(), "number of pages read and compressed at once when loading the site")
+
 type Dir struct {
 	Name string
 
@@ -21,48 +27,130 @@ type Dir struct {
 	Files  map[string]*ContentFile
 
 	Directories map[string]*Dir
+
+	// Views holds the layouts pages can be shown in with ?view=, read from
+	// layout.<view>.html files in the directory or its parents.
+	Views map[string]*LayoutFile
+
+	// Default is the name of the page served for the directory, from its
+	// DirConfigFilename, or empty for -default.
+	Default string
+
+	// Recursive is set when the directory's archives list the pages of its
+	// sub-directories as well, as with -recursive-archives.
+	Recursive bool
+
+	// Redirects and ErrorPages are read for the root directory only.
+	Redirects  *Redirects
+	ErrorPages map[int]*ContentFile
 }
 
 type ContentFile struct {
 	Name string
+	FrontMatter
 
 	Content []byte
 	Hash    []byte
+	ModTime time.Time
+
+	// Source is the page as written, without its front matter, served
+	// with ?format=md.
+	Source []byte
+
+	// Lang is the language tag from the file name, as in "about.fr.md".
+	Lang string
+
+	// ReadingTime is the estimated number of minutes it takes to read
+	// the page.
+	ReadingTime int
+
+	// ContentType is sent with the page. Raw pages are served without a
+	// layout.
+	ContentType string
+	Raw         bool
+
+	// TOC holds the page's h2 and h3 headings; TOCHTML is the list of
+	// links to them substituted for {{toc}} in layouts.
+	TOC     []Heading
+	TOCHTML []byte
+
+	// RelatedHTML lists the pages sharing tags with this one, for
+	// {{related}} in layouts, and MetaHTML holds the tags describing the
+	// page for {{meta}}.
+	RelatedHTML []byte
+	MetaHTML    []byte
+
+	// CanonicalHTML is the link to the page's canonical URL for
+	// {{canonical}}.
+	CanonicalHTML []byte
+
+	// Layout is the layout the page is shown in by default and Size the
+	// length of the page rendered within it, both set as the tree is
+	// loaded when Prerendered is. Rendered is that page, unless it is
+	// streamed, and GzipContent and BrotliContent are it compressed, ready
+	// to be written to clients accepting either encoding.
+	Layout        *LayoutFile
+	Prerendered   bool
+	Size          int
+	Rendered      []byte
+	GzipContent   []byte
+	BrotliContent []byte
 }
 
+// LayoutFile is a layout split into static text and placeholders. The
+// parts of a nested layout are placed within its parent's {{content}}.
+// View is the name of the view the layout is for, or empty for the
+// layout pages are shown in by default. Header and Footer are the rendered
+// -header-md and -footer-md, written either side of {{content}}.
 type LayoutFile struct {
-	Pre, Post []byte
-	Hash      []byte
+	Parts   []LayoutPart
+	Hash    []byte
+	ModTime time.Time
+	View    string
+
+	Header []byte
+	Footer []byte
 }
 
 func ReadTree(base string) (*Dir, []error) {
 	errors := make([]error, 0)
 
-	var parse func(current string, parentLayout *LayoutFile) *Dir
+	var parse func(current string, parentLayout *LayoutFile, parentViews map[string]*LayoutFile) *Dir
 
-	parse = func(current string, parentLayout *LayoutFile) *Dir {
+	parse = func(current string, parentLayout *LayoutFile, parentViews map[string]*LayoutFile) *Dir {
 		dir := &Dir{}
 		dir.Name = filepath.Base(current)
 		dir.Layout = parentLayout
+		dir.Views = make(map[string]*LayoutFile, len(parentViews))
+		for v, l := range parentViews {
+			dir.Views[v] = l
+		}
 		dir.Files = make(map[string]*ContentFile, 0)
 
-		fd, err := os.Open(current)
-		if err != nil {
-			errors = append(errors, fmt.Errorf("Failed to open directory '%v': %v", current, err))
-			return nil
+		if c, err := readDirConfig(current); err != nil {
+			errors = append(errors, fmt.Errorf("Failed to read directory settings '%v': %v",
+				filepath.Join(current, DirConfigFilename), err))
+		} else {
+			dir.Default = c.Default
+			dir.Recursive = c.Recursive
 		}
 
-		files, err := fd.Readdir(0)
+		files, err := readDir(current)
 		if err != nil {
+			if files == nil {
+				errors = append(errors, fmt.Errorf("Failed to open directory '%v': %v", current, err))
+				return nil
+			}
 			errors = append(errors, fmt.Errorf("Failed to enumerate directory '%v': %v", current, err))
 		}
 
 		subdirs := make([]string, 0)
+		pages := make([]string, 0)
 
 		for _, file := range files {
 			n := file.Name()
 
-			if n[0] == '.' {
+			if n[0] == '.' || (current == base && (n == *optPartials || n == *optErrors)) {
 				continue
 			}
 
@@ -71,87 +159,295 @@ func ReadTree(base string) (*Dir, []error) {
 				continue
 			}
 
-			var err error
-
 			switch {
-			case filepath.Ext(n) == ".md":
-				var c *ContentFile
-				if c, err = readContentFile(current, n); err != nil {
-					errors = append(errors, fmt.Errorf("Failed to read content file '%v': %v",
+			case isPage(n):
+				pages = append(pages, n)
+			case n == LayoutFilename:
+				var err error
+				if dir.Layout, err = readLayoutFile(current, n, parentLayout); err != nil {
+					errors = append(errors, fmt.Errorf("Failed to read layout file '%v': %v",
 						filepath.Join(current, n), err))
 				}
-
-				dir.Files[c.Name] = c
-			case n == "layout.html":
-				if dir.Layout, err = readLayoutFile(current, n, parentLayout); err != nil {
+			case isViewLayout(n):
+				v, _ := viewName(n)
+				if l, err := readLayoutFile(current, n, parentViews[v]); err != nil {
 					errors = append(errors, fmt.Errorf("Failed to read layout file '%v': %v",
 						filepath.Join(current, n), err))
+				} else {
+					l.View = v
+					dir.Views[v] = l
 				}
 			}
 		}
 
+		contents := make([]*ContentFile, len(pages))
+		readErrors := make([]error, len(pages))
+		parallel(len(pages), func(i int) {
+			contents[i], readErrors[i] = readContentFile(current, pages[i])
+		})
+
+		sources := make(map[string]string)
+		for i, n := range pages {
+			c := contents[i]
+			if readErrors[i] != nil {
+				errors = append(errors, fmt.Errorf("Failed to read content file '%v': %v",
+					filepath.Join(current, n), readErrors[i]))
+				continue
+			}
+
+			// names differ only by extension, so the earlier one in -ext wins
+			if other, ok := sources[c.Name]; ok {
+				hidden, shown := n, other
+				if extRank(n) < extRank(other) {
+					hidden, shown = other, n
+				}
+				log.Printf("Content file '%v' is hidden by '%v'\n", filepath.Join(current, hidden), filepath.Join(current, shown))
+				if hidden == n {
+					continue
+				}
+			}
+
+			dir.Files[c.Name] = c
+			sources[c.Name] = n
+		}
+
 		if len(subdirs) > 0 {
 			dir.Directories = make(map[string]*Dir)
 
 			for _, subdir := range subdirs {
 				n := filepath.Base(subdir)
-				dir.Directories[n] = parse(subdir, dir.Layout)
+				dir.Directories[n] = parse(subdir, dir.Layout, dir.Views)
 			}
 		}
 
 		return dir
 	}
 
-	return parse(base, nil), errors
+	dir := parse(base, nil, nil)
+
+	if dir != nil {
+		var err error
+		if dir.Redirects, err = readRedirects(base); err != nil {
+			errors = append(errors, fmt.Errorf("Failed to read redirects file '%v': %v",
+				filepath.Join(base, RedirectsFilename), err))
+		}
+
+		var errs []error
+		dir.ErrorPages, errs = readErrorPages(base)
+		errors = append(errors, errs...)
+
+		if header, err := readFragment(*optHeaderMD); err != nil {
+			errors = append(errors, fmt.Errorf("Failed to read header file '%v': %v", *optHeaderMD, err))
+		} else if footer, err := readFragment(*optFooterMD); err != nil {
+			errors = append(errors, fmt.Errorf("Failed to read footer file '%v': %v", *optFooterMD, err))
+		} else {
+			setHeaderFooter(dir, header, footer)
+		}
+
+		dir.Walk(func(p string, d *Dir) {
+			for n, cf := range d.Files {
+				cf.CanonicalHTML = canonicalHTML(canonicalURL(d, p, n))
+				cf.Hash = pageHash(cf)
+			}
+		})
+
+		setRelated(dir)
+
+		var pages []*ContentFile
+		var layouts []*LayoutFile
+		dir.Walk(func(p string, d *Dir) {
+			for _, cf := range d.Files {
+				pages = append(pages, cf)
+				layouts = append(layouts, pageLayout(d, cf))
+			}
+		})
+
+		parallel(len(pages), func(i int) {
+			prerender(pages[i], layouts[i])
+		})
+	}
+
+	return dir, errors
+}
+
+// prerender renders the page within the layout, keeping its length and,
+// unless it is streamed, the page itself and its compressed copies.
+func prerender(cf *ContentFile, layout *LayoutFile) {
+	b := &bytes.Buffer{}
+	writeLayout(b, layout, cf)
+
+	cf.Layout, cf.Prerendered, cf.Size = layout, true, b.Len()
+	if streamed(cf.Size) {
+		return
+	}
+
+	cf.Rendered = b.Bytes()
+	if cf.Size > *optGzipMinBytes {
+		cf.GzipContent = compressBytes("gzip", cf.Rendered)
+		cf.BrotliContent = compressBytes("br", cf.Rendered)
+	}
+}
+
+// parallel calls f for each of 0 to n-1 on up to -workers goroutines,
+// returning once all the calls have.
+func parallel(n int, f func(i int)) {
+	workers := *optWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				f(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+func isPage(name string) bool {
+	_, ok := pagePipeline(name)
+	return ok
 }
 
 func readContentFile(dir, name string) (*ContentFile, error) {
-	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	b, err := readFile(filepath.Join(dir, name))
 
 	if err != nil {
 		return nil, err
 	}
 
+	info, err := statFile(filepath.Join(dir, name))
+
+	if err != nil {
+		return nil, err
+	}
+
+	pl, _ := pagePipeline(name)
+	if pl.raw {
+		cf := &ContentFile{Name: strings.TrimSuffix(name, filepath.Ext(name)), Content: b, Raw: true}
+		cf.ContentType = pl.contentType()
+		cf.Lang = pageLang(cf.Name)
+		cf.Hash = hash(b)
+		cf.ModTime = info.ModTime()
+		return cf, nil
+	}
+
+	fm, body, err := parseFrontMatter(b)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid front matter: %v", err)
+	}
+
+	for _, w := range frontMatterWarnings(b) {
+		log.Printf("Warning: front matter of '%v' %v\n", filepath.Join(dir, name), w)
+	}
+
 	cf := &ContentFile{}
-	cf.Name = name[:len(name)-3]
-	cf.Content = md.MarkdownCommon(b)
-	cf.Hash = hash(cf.Content)
+	cf.Name = strings.TrimSuffix(name, filepath.Ext(name))
+	cf.ContentType = pl.contentType()
+	cf.FrontMatter = fm
+	cf.Lang = pageLang(cf.Name)
+	cf.Source = body
+	if pl.fragment {
+		if cf.Content, err = newFragmentRenderer().Render(body); err != nil {
+			return nil, err
+		}
+	} else {
+		rel, _ := filepath.Rel(root, filepath.Join(dir, name))
+		if body, err = expandIncludes(body, []string{path.Clean("/" + filepath.ToSlash(rel))}); err != nil {
+			return nil, err
+		}
+
+		r := newRenderer()
+		if cf.Content, err = r.Render(body); err != nil {
+			return nil, err
+		}
+		if t, ok := r.(tocRenderer); ok {
+			cf.TOC = t.TOC()
+		}
+	}
+	cf.TOCHTML = tocHTML(cf.TOC)
+	cf.ReadingTime = readingTime(cf.Content)
+	cf.MetaHTML = metaHTML(cf)
+	cf.Hash = pageHash(cf)
+	cf.ModTime = info.ModTime()
 
 	return cf, nil
 }
 func readLayoutFile(dir, name string, parent *LayoutFile) (*LayoutFile, error) {
-	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	b, err := readFile(filepath.Join(dir, name))
 
 	if err != nil {
 		return nil, err
 	}
 
-	spl := bytes.Split(b, []byte("{{content}}"))
+	info, err := statFile(filepath.Join(dir, name))
 
-	if len(spl) != 2 {
-		return nil, fmt.Errorf("{{content}} token not found")
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := parseLayout(b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parent == nil && *optDev {
+		parts = injectLiveReload(parts)
 	}
 
 	lf := &LayoutFile{}
-	lf.Pre, lf.Post = spl[0], spl[1]
-	lf.Hash = hash(b)
+	lf.Parts = parts
+	lf.Hash = layoutHash(parts)
+	lf.ModTime = info.ModTime()
 
 	if parent != nil {
-		x := make([]byte, len(lf.Pre)+len(parent.Pre))
-		copy(x, parent.Pre)
-		copy(x[len(parent.Pre):], lf.Pre)
+		x := make([]LayoutPart, 0, len(parent.Parts)+len(lf.Parts))
+		for _, p := range parent.Parts {
+			if p.Token == "content" {
+				x = append(x, lf.Parts...)
+			} else {
+				x = append(x, p)
+			}
+		}
 
-		lf.Pre = x
-		lf.Post = append(lf.Post, parent.Post...)
+		lf.Parts = x
 
 		for i := 0; i < 16; i++ {
 			lf.Hash[i] ^= parent.Hash[i]
 		}
+
+		if parent.ModTime.After(lf.ModTime) {
+			lf.ModTime = parent.ModTime
+		}
 	}
 
 	return lf, nil
 }
 
+// pageHash identifies what the page puts into its layout.
+func pageHash(cf *ContentFile) []byte {
+	b := &bytes.Buffer{}
+	b.Write(cf.Content)
+	b.WriteString(cf.Title)
+	b.Write(cf.MetaHTML)
+	b.Write(cf.RelatedHTML)
+	b.Write(cf.CanonicalHTML)
+	return hash(b.Bytes())
+}
+
 func hash(value []byte) []byte {
 	h := md5.New()
 	h.Write(value)
@@ -179,3 +475,39 @@ func (d *Dir) FindByPath(path string) *Dir {
 
 	return match
 }
+
+// Walk calls fn for d and each of its sub-directories, in name order,
+// passing the URL path of the directory with a trailing slash.
+func (d *Dir) Walk(fn func(p string, dir *Dir)) {
+	var walk func(p string, dir *Dir)
+
+	walk = func(p string, dir *Dir) {
+		if dir == nil {
+			return
+		}
+
+		fn(p, dir)
+
+		names := make([]string, 0, len(dir.Directories))
+		for n := range dir.Directories {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		for _, n := range names {
+			walk(path.Join(p, n)+"/", dir.Directories[n])
+		}
+	}
+
+	walk("/", d)
+}
+
+// FileNames returns the names of the content files in d, sorted.
+func (d *Dir) FileNames() []string {
+	names := make([]string, 0, len(d.Files))
+	for n := range d.Files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
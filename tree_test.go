@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDirectoryLayoutOverride(t *testing.T) {
+	testSite(t, map[string]string{
+		"layout.html":           "<main>{{content}}</main>",
+		"about.md":              "About",
+		"blog/layout.html":      "<article>{{content}}</article>",
+		"blog/post.md":          "Post",
+		"blog/2023/deep.md":     "Deep",
+		"docs/guide.md":         "Guide",
+		"docs/api/reference.md": "Reference",
+	})
+	h := http.HandlerFunc(renderPage)
+
+	for u, want := range map[string]string{
+		"/about":              "<main><p>About</p>\n</main>",
+		"/blog/post":          "<main><article><p>Post</p>\n</article></main>",
+		"/blog/2023/deep":     "<main><article><p>Deep</p>\n</article></main>",
+		"/docs/guide":         "<main><p>Guide</p>\n</main>",
+		"/docs/api/reference": "<main><p>Reference</p>\n</main>",
+	} {
+		if body := get(h, u).Body.String(); body != want {
+			t.Errorf("%v: body %q, want %q", u, body, want)
+		}
+	}
+}
+
+func TestLayoutRequiresContent(t *testing.T) {
+	writeSite(t, map[string]string{"layout.html": "<main></main>", "about.md": "About"})
+
+	if _, errs := ReadTree(root); len(errs) == 0 || !strings.Contains(errs[0].Error(), "{{content}}") {
+		t.Errorf("errors %v, want one about {{content}}", errs)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	for _, workers := range []string{"0", "1", "4", "100"} {
+		setFlag(t, "workers", workers)
+
+		var calls [50]int32
+		parallel(len(calls), func(i int) { atomic.AddInt32(&calls[i], 1) })
+		for i, n := range calls {
+			if n != 1 {
+				t.Errorf("-workers=%v: f(%v) called %v times", workers, i, n)
+			}
+		}
+	}
+	parallel(0, func(int) { t.Error("f called for n of 0") })
+}
+
+func TestLoadManyPagesConcurrently(t *testing.T) {
+	setFlag(t, "workers", "8")
+	files := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		files[fmt.Sprintf("dir%v/page%v.md", i%10, i)] = strings.Repeat(fmt.Sprintf("Page %v. ", i), 200)
+	}
+	d := testSite(t, files)
+
+	for i := 0; i < 200; i++ {
+		cf := d.Directories[fmt.Sprintf("dir%v", i%10)].Files[fmt.Sprintf("page%v", i)]
+		if cf == nil || !strings.Contains(string(cf.Content), fmt.Sprintf("Page %v.", i)) || cf.GzipContent == nil {
+			t.Fatalf("page%v not loaded and compressed", i)
+		}
+	}
+}
+
+func TestPagesServedFromMemory(t *testing.T) {
+	d := testSite(t, map[string]string{
+		"about.md":     strings.Repeat("About. ", 300),
+		"blog/post.md": "Post",
+	})
+
+	for _, cf := range []*ContentFile{d.Files["about"], d.Directories["blog"].Files["post"]} {
+		if !cf.Prerendered || cf.Rendered == nil {
+			t.Errorf("page %v not rendered when loaded", cf.Name)
+		}
+	}
+	if d.Files["about"].GzipContent == nil || d.Files["about"].BrotliContent == nil {
+		t.Error("large page not compressed when loaded")
+	}
+
+	// the files are not read again once loaded
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"/about", "/blog/post"} {
+		if w := get(http.HandlerFunc(renderPage), u, "Accept-Encoding", "gzip"); w.Code != 200 {
+			t.Errorf("%v after removing the files: status %v, want 200", u, w.Code)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// viewName returns the name of the view a layout file such as
+// layout.print.html is for.
+func viewName(filename string) (string, bool) {
+	if !strings.HasPrefix(filename, "layout.") || !strings.HasSuffix(filename, ".html") || len(filename) <= len("layout..html") {
+		return "", false
+	}
+
+	name := filename[len("layout.") : len(filename)-len(".html")]
+	return name, !strings.Contains(name, ".")
+}
+
+// viewLayout returns the layout of the view named by the ?view= query of
+// r, if d has one, or else layout. Pages without a layout have no views.
+func viewLayout(r *http.Request, d *Dir, layout *LayoutFile) *LayoutFile {
+	if layout == nil {
+		return nil
+	}
+	if v := d.Views[r.URL.Query().Get("view")]; v != nil {
+		return v
+	}
+	return layout
+}
+
+func isViewLayout(filename string) bool {
+	_, ok := viewName(filename)
+	return ok
+}
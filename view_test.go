@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestViewName(t *testing.T) {
+	for filename, want := range map[string]string{"layout.print.html": "print", "layout.amp.html": "amp", "layout.html": "", "layout..html": "", "layout.a.b.html": "", "print.html": ""} {
+		if got, ok := viewName(filename); ok != (want != "") || (ok && got != want) {
+			t.Errorf("viewName(%q) = %q, %v; want %q", filename, got, ok, want)
+		}
+	}
+}
+
+func TestViews(t *testing.T) {
+	testSite(t, map[string]string{
+		"layout.print.html": "<pre>{{content}}</pre>",
+		"about.md":          "About",
+		"blog/post.md":      "Post",
+	})
+	h := http.HandlerFunc(renderPage)
+
+	page := get(h, "/about")
+	print := get(h, "/about?view=print")
+	if !strings.HasPrefix(print.Body.String(), "<pre><p>About</p>") || !strings.HasPrefix(page.Body.String(), "<html>") {
+		t.Errorf("page %q, print view %q", page.Body.String(), print.Body.String())
+	}
+	if page.Header().Get("Etag") == print.Header().Get("Etag") {
+		t.Error("the page and its print view have the same Etag")
+	}
+
+	// views are inherited by sub-directories, and unknown views ignored
+	if body := get(h, "/blog/post?view=print").Body.String(); !strings.HasPrefix(body, "<pre>") {
+		t.Errorf("sub-directory print view %q", body)
+	}
+	if body := get(h, "/about?view=nonsense").Body.String(); body != page.Body.String() {
+		t.Errorf("unknown view %q, want the page", body)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var optWatch = flag.Bool("watch", false, "reload pages and layouts when files under the path change")
+
+var watchInterval = 500 * time.Millisecond
+var watchDebounce = 200 * time.Millisecond
+
+type fileStamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+func setupWatch() {
+	if !*optWatch {
+		return
+	}
+
+	go func() {
+		last := scanTree()
+
+		for {
+			time.Sleep(watchInterval)
+
+			cur := scanTree()
+			if len(changedFiles(last, cur)) == 0 {
+				continue
+			}
+
+			// wait for the files to settle so a single save only reloads once
+			for {
+				time.Sleep(watchDebounce)
+				next := scanTree()
+				if len(changedFiles(cur, next)) == 0 {
+					break
+				}
+				cur = next
+			}
+
+			for _, p := range changedFiles(last, cur) {
+				log.Printf("Changed: %v\n", p)
+			}
+
+			last = cur
+			reload()
+		}
+	}()
+}
+
+// scanTree records the modification time and size of every file that
+// ReadTree would read, and of every file in the public directory, whose
+// fingerprints layouts link to. Files which disappear during the walk are
+// ignored.
+func scanTree() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+
+	walkFiles(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		n := info.Name()
+
+		if p != root && n[0] == '.' && n != DirConfigFilename {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.IsDir() && (isPage(n) || n == LayoutFilename || isViewLayout(n) || n == DirConfigFilename || p == filepath.Join(root, RedirectsFilename) || p == filepath.Join(root, SiteFilename)) {
+			stamps[p] = fileStamp{info.ModTime(), info.Size()}
+		}
+
+		return nil
+	})
+
+	walkFiles(filepath.Join(root, *optStaticDir), func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			stamps[p] = fileStamp{info.ModTime(), info.Size()}
+		}
+		return nil
+	})
+
+	// the archive template need not be named like anything above
+	if *optIndexTmpl != "" {
+		p := filepath.Join(root, *optIndexTmpl)
+		if info, err := statFile(p); err == nil && !info.IsDir() {
+			stamps[p] = fileStamp{info.ModTime(), info.Size()}
+		}
+	}
+
+	return stamps
+}
+
+func changedFiles(a, b map[string]fileStamp) []string {
+	changed := make([]string, 0)
+
+	for p, s := range b {
+		if o, ok := a[p]; !ok || o != s {
+			changed = append(changed, p)
+		}
+	}
+	for p := range a {
+		if _, ok := b[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+
+	return changed
+}
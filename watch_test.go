@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchedPublicAssetsRefingerprinted(t *testing.T) {
+	dir := writeSite(t, map[string]string{
+		"layout.html":       `<link rel="stylesheet" href="{{asset "site.css"}}">{{content}}`,
+		"about.md":          "About",
+		".public/site.css":  "body { color: red }",
+		".hidden/ignore.md": "Ignored",
+	})
+	loadSite(t)
+	h := testHandler()
+
+	before, err := assetURL("site.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := scanTree()
+	css := filepath.Join(dir, ".public", "site.css")
+	if _, ok := last[css]; !ok {
+		t.Fatalf("the public directory is not watched: %v", last)
+	}
+	if _, ok := last[filepath.Join(dir, ".hidden", "ignore.md")]; ok {
+		t.Error("a hidden directory is watched")
+	}
+
+	if err := os.WriteFile(css, []byte("body { color: blue; }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	os.Chtimes(css, later, later)
+
+	if changed := changedFiles(last, scanTree()); len(changed) != 1 || changed[0] != css {
+		t.Fatalf("changed %v, want %v", changed, css)
+	}
+	if !reload() {
+		t.Fatal("reload failed")
+	}
+
+	after, err := assetURL("site.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after == before {
+		t.Fatalf("asset URL %v unchanged after the file changed", after)
+	}
+	if body := get(h, "/about").Body.String(); !strings.Contains(body, after) {
+		t.Errorf("page %q does not link to %v", body, after)
+	}
+
+	w := get(h, after)
+	if w.Code != 200 || w.Body.String() != "body { color: blue; }" {
+		t.Errorf("%v: status %v, body %q", after, w.Code, w.Body)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=31536000, immutable" {
+		t.Errorf("%v: Cache-Control %q, want it immutable", after, cc)
+	}
+}
+
+func TestWatchedPagesReloaded(t *testing.T) {
+	dir := writeSite(t, map[string]string{
+		"layout.html": testLayout,
+		"about.md":    "About",
+		"old.md":      "Old",
+		"notes.bak":   "Not a page",
+	})
+	loadSite(t)
+	h := testHandler()
+
+	last := scanTree()
+	if _, ok := last[filepath.Join(dir, "notes.bak")]; ok {
+		t.Error("a file which is not part of the site is watched")
+	}
+
+	about := filepath.Join(dir, "about.md")
+	if err := os.WriteFile(about, []byte("About us"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.md"), []byte("New"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "old.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := changedFiles(last, scanTree())
+	sort.Strings(changed)
+	want := []string{about, filepath.Join(dir, "new.md"), filepath.Join(dir, "old.md")}
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("changed %v, want %v", changed, want)
+	}
+
+	if !reload() {
+		t.Fatal("reload failed")
+	}
+	if body := get(h, "/about").Body.String(); !strings.Contains(body, "About us") {
+		t.Errorf("/about after a reload %q", body)
+	}
+	if w := get(h, "/new"); w.Code != 200 {
+		t.Errorf("/new after a reload: status %v, want 200", w.Code)
+	}
+	if w := get(h, "/old"); w.Code != 404 {
+		t.Errorf("/old after a reload: status %v, want 404", w.Code)
+	}
+}
+
+func TestWatchedIndexTemplateReloaded(t *testing.T) {
+	setFlag(t, "index-tmpl", ".templates/archive.tmpl")
+	dir := writeSite(t, map[string]string{
+		"layout.html":             testLayout,
+		".templates/archive.tmpl": `<h1>{{.Title}}</h1>`,
+		"blog/post.md":            "---\ndate: 2023-06-01\n---\nPost",
+	})
+	loadSite(t)
+	t.Cleanup(func() { indexTemplate = nil })
+	h := testHandler()
+
+	last := scanTree()
+	tmpl := filepath.Join(dir, ".templates", "archive.tmpl")
+	if _, ok := last[tmpl]; !ok {
+		t.Fatalf("the -index-tmpl file is not watched: %v", last)
+	}
+
+	if err := os.WriteFile(tmpl, []byte(`<h1>Archive of {{.Title}}</h1>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	os.Chtimes(tmpl, later, later)
+
+	if changed := changedFiles(last, scanTree()); len(changed) != 1 || changed[0] != tmpl {
+		t.Fatalf("changed %v, want %v", changed, tmpl)
+	}
+	if !reload() {
+		t.Fatal("reload failed")
+	}
+	if body := get(h, "/blog/2023/").Body.String(); !strings.Contains(body, "<h1>Archive of 2023</h1>") {
+		t.Errorf("archive after a reload %q", body)
+	}
+}